@@ -0,0 +1,256 @@
+// Package auth authenticates API callers with bearer tokens.
+//
+// Agent tokens are bound to a config agent ID; user keys identify a human
+// with a role. Both are stored as SHA-256 hashes in api_keys, so a leaked
+// database doesn't leak usable tokens. ADMIN_TOKEN is a bootstrap admin
+// credential for minting the first keys.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/config"
+	"github.com/alghanim/agentboard/backend/db"
+)
+
+// Key kinds and roles.
+const (
+	KindAgent = "agent"
+	KindUser  = "user"
+
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Identity is the authenticated caller.
+type Identity struct {
+	Kind    string `json:"kind"`               // "agent" or "user"
+	AgentID string `json:"agent_id,omitempty"` // agent tokens only
+	Name    string `json:"name"`
+	Role    string `json:"role"`
+	KeyID   string `json:"key_id,omitempty"`
+}
+
+// Actor is the name recorded in activity and history for this caller.
+func (id *Identity) Actor() string {
+	if id.Kind == KindAgent {
+		return id.AgentID
+	}
+	return id.Name
+}
+
+// IsAdmin reports whether the caller holds the admin role.
+func (id *Identity) IsAdmin() bool {
+	return id.Role == RoleAdmin
+}
+
+// Options configures the middleware.
+type Options struct {
+	// Enabled turns authentication on. When off every request is let
+	// through and callers identify themselves with X-Agent-ID as before.
+	Enabled bool
+	// AdminToken, if set, is accepted as an admin user credential.
+	AdminToken string
+	// Public lists path prefixes that never require a token.
+	Public []string
+}
+
+var (
+	opts Options
+
+	cacheMu sync.Mutex
+	cache   = map[string]cachedIdentity{}
+)
+
+// cacheTTL bounds how long a looked-up key is trusted without hitting the
+// database. Revocations through this process invalidate immediately.
+const cacheTTL = 30 * time.Second
+
+type cachedIdentity struct {
+	id      *Identity
+	expires time.Time
+}
+
+type ctxKey struct{}
+
+// Configure sets the middleware options. Call once at startup.
+func Configure(o Options) {
+	opts = o
+}
+
+// Enabled reports whether authentication is enforced.
+func Enabled() bool {
+	return opts.Enabled
+}
+
+// FromContext returns the caller's identity, if the request was authenticated.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(ctxKey{}).(*Identity)
+	return id, ok && id != nil
+}
+
+// FromRequest is FromContext for a request.
+func FromRequest(r *http.Request) (*Identity, bool) {
+	return FromContext(r.Context())
+}
+
+// Middleware authenticates every request that isn't public. It reads the
+// token from "Authorization: Bearer <token>", or from ?token= for
+// WebSocket and EventSource clients that can't set headers.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := tokenFromRequest(r)
+		if !opts.Enabled {
+			// Still attach an identity if one was offered, so key endpoints
+			// and actors work the same while auth is being rolled out.
+			if token != "" {
+				if id, err := Authenticate(token); err == nil {
+					r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, id))
+				}
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions || isPublic(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if token == "" {
+			unauthorized(w, "Missing bearer token")
+			return
+		}
+		id, err := Authenticate(token)
+		if err != nil {
+			// Only our own messages reach the caller; a database error
+			// is logged and reported as an invalid token.
+			if _, ok := err.(authError); !ok {
+				log.Printf("[auth] authenticate: %v", err)
+				err = errInvalidToken
+			}
+			unauthorized(w, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, id)))
+	})
+}
+
+// Authenticate resolves a token to an identity.
+func Authenticate(token string) (*Identity, error) {
+	if opts.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(opts.AdminToken)) == 1 {
+		return &Identity{Kind: KindUser, Name: "admin", Role: RoleAdmin}, nil
+	}
+
+	hash := HashToken(token)
+	cacheMu.Lock()
+	c, ok := cache[hash]
+	cacheMu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.id, nil
+	}
+
+	id := &Identity{}
+	var agentID sql.NullString
+	err := db.DB.QueryRow(`
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE key_hash = $1 AND revoked_at IS NULL
+		RETURNING id, kind, agent_id, name, role`, hash).
+		Scan(&id.KeyID, &id.Kind, &agentID, &id.Name, &id.Role)
+	if err == sql.ErrNoRows {
+		return nil, errInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if id.Kind == KindAgent {
+		// The agent may have been removed from agents.yaml since minting.
+		ca := config.GetAgentByID(agentID.String)
+		if ca == nil {
+			return nil, errUnknownAgent
+		}
+		id.AgentID = ca.ID
+	}
+
+	cacheMu.Lock()
+	cache[hash] = cachedIdentity{id: id, expires: time.Now().Add(cacheTTL)}
+	cacheMu.Unlock()
+	return id, nil
+}
+
+// Forget drops a key from the lookup cache after it is revoked.
+func Forget(keyID string) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	for hash, c := range cache {
+		if c.id.KeyID == keyID {
+			delete(cache, hash)
+		}
+	}
+}
+
+// NewToken generates a token and returns it with its hash and display prefix.
+// Only the hash and prefix are stored.
+func NewToken(kind string) (token, hash, prefix string, err error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	tag := "ak"
+	if kind == KindUser {
+		tag = "uk"
+	}
+	token = "ab" + tag + "_" + hex.EncodeToString(b)
+	return token, HashToken(token), token[:12], nil
+}
+
+// HashToken returns the hex SHA-256 of a token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type authError string
+
+func (e authError) Error() string { return string(e) }
+
+const (
+	errInvalidToken = authError("Invalid or revoked token")
+	errUnknownAgent = authError("Token is bound to an agent that is no longer configured")
+)
+
+func tokenFromRequest(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		if len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+			return strings.TrimSpace(h[7:])
+		}
+		return ""
+	}
+	return r.URL.Query().Get("token")
+}
+
+func isPublic(path string) bool {
+	for _, p := range opts.Public {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer realm="agentboard"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -4,12 +4,16 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -23,6 +27,7 @@ type AgentNode struct {
 	Team      string       `yaml:"team"`
 	TeamColor string       `yaml:"team_color"`
 	IsLead    bool         `yaml:"is_lead"`
+	Labels    []string     `yaml:"labels,omitempty"`
 	Children  []*AgentNode `yaml:"children,omitempty"`
 
 	// Set during flattening — not in YAML
@@ -37,13 +42,273 @@ type Branding struct {
 	Theme       string `yaml:"theme" json:"theme"`
 }
 
+// Workflow is a named task pipeline: the statuses a task may take and the
+// transitions allowed between them. Teams listed in Teams use this workflow;
+// every other team uses the one named DefaultWorkflowName. Done is the status
+// that completes a task: reaching it stamps completed_at and releases the
+// task's dependents, and analytics count tasks in it as completed.
+type Workflow struct {
+	Name        string              `yaml:"-" json:"name"`
+	Statuses    []string            `yaml:"statuses" json:"statuses"`
+	Initial     string              `yaml:"initial" json:"initial"`
+	Done        string              `yaml:"done" json:"done"`
+	Transitions map[string][]string `yaml:"transitions" json:"transitions"`
+	Teams       []string            `yaml:"teams" json:"teams,omitempty"`
+}
+
+// HasStatus reports whether status is defined by the workflow.
+func (wf Workflow) HasStatus(status string) bool {
+	for _, s := range wf.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransition reports whether a task may move from one status to another.
+// Staying in the same status is always allowed.
+func (wf Workflow) CanTransition(from, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, s := range wf.Transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Working returns the status a task takes when an agent starts on it:
+// "progress" if the workflow has it, otherwise the first transition out of
+// Initial.
+func (wf Workflow) Working() string {
+	if wf.HasStatus("progress") {
+		return "progress"
+	}
+	if next := wf.Transitions[wf.Initial]; len(next) > 0 {
+		return next[0]
+	}
+	return "progress"
+}
+
+// DefaultWorkflowName is the workflow used for tasks whose team has no
+// workflow of its own.
+const DefaultWorkflowName = "default"
+
+// defaultWorkflow mirrors the board's original hardcoded pipeline and is used
+// when agents.yaml does not define a "default" workflow.
+func defaultWorkflow() Workflow {
+	return Workflow{
+		Name:     DefaultWorkflowName,
+		Statuses: []string{"backlog", "todo", "next", "progress", "review", "done", "blocked"},
+		Initial:  "todo",
+		Done:     "done",
+		Transitions: map[string][]string{
+			"todo":     {"progress", "backlog"},
+			"backlog":  {"todo", "next"},
+			"next":     {"progress"},
+			"progress": {"review", "blocked", "todo"},
+			"review":   {"done", "progress"},
+			"blocked":  {"todo", "progress"},
+			"done":     {},
+		},
+	}
+}
+
+// RoutingRule routes matching tasks to a team during auto-assignment.
+// Label and Priority are match conditions (empty matches anything); the
+// remaining fields steer candidate selection.
+type RoutingRule struct {
+	Name             string   `yaml:"name" json:"name"`
+	Label            string   `yaml:"label" json:"label,omitempty"`
+	Priority         string   `yaml:"priority" json:"priority,omitempty"`
+	Team             string   `yaml:"team" json:"team,omitempty"`
+	PreferChildrenOf string   `yaml:"prefer_children_of" json:"prefer_children_of,omitempty"`
+	Skills           []string `yaml:"skills" json:"skills,omitempty"`
+}
+
+// Routing configures the auto-assignment engine.
+type Routing struct {
+	MaxWIP int           `yaml:"max_wip" json:"max_wip"`
+	Rules  []RoutingRule `yaml:"rules" json:"rules"`
+}
+
+// EscalationThreshold is how long a task may sit before its assignee's lead
+// is notified, and then the lead's parent. A zero Parent skips that level.
+type EscalationThreshold struct {
+	Lead   time.Duration `yaml:"lead" json:"lead"`
+	Parent time.Duration `yaml:"parent" json:"parent"`
+}
+
+// Escalation configures the stuck/overdue task scheduler. Stuck thresholds
+// count from the task's last update while in progress; overdue thresholds
+// count from its due_date. Both maps are keyed by priority, with "default"
+// as the fallback.
+type Escalation struct {
+	Interval time.Duration                  `yaml:"interval" json:"interval"`
+	Stuck    map[string]EscalationThreshold `yaml:"stuck" json:"stuck"`
+	Overdue  map[string]EscalationThreshold `yaml:"overdue" json:"overdue"`
+}
+
+// StuckThreshold returns the stuck threshold for a task priority.
+func (e Escalation) StuckThreshold(priority string) EscalationThreshold {
+	return thresholdFor(e.Stuck, priority)
+}
+
+// OverdueThreshold returns the overdue threshold for a task priority.
+func (e Escalation) OverdueThreshold(priority string) EscalationThreshold {
+	return thresholdFor(e.Overdue, priority)
+}
+
+func thresholdFor(m map[string]EscalationThreshold, priority string) EscalationThreshold {
+	if t, ok := m[strings.ToLower(priority)]; ok {
+		return t
+	}
+	return m["default"]
+}
+
+// withDefaults fills in anything left unset in agents.yaml: a 5 minute check
+// interval, stuck after 2h (parent after 4h), and overdue immediately (parent
+// after a day).
+func (e Escalation) withDefaults() Escalation {
+	if e.Interval <= 0 {
+		e.Interval = 5 * time.Minute
+	}
+	stuck := map[string]EscalationThreshold{"default": {Lead: 2 * time.Hour, Parent: 4 * time.Hour}}
+	for k, v := range e.Stuck {
+		stuck[strings.ToLower(k)] = v
+	}
+	overdue := map[string]EscalationThreshold{"default": {Lead: 0, Parent: 24 * time.Hour}}
+	for k, v := range e.Overdue {
+		overdue[strings.ToLower(k)] = v
+	}
+	e.Stuck, e.Overdue = stuck, overdue
+	return e
+}
+
+// ModelPrice is a model's rate card in USD per 1M tokens. Model matches a
+// model ID exactly or, failing that, as a substring (e.g. "opus"); the
+// longest substring wins. EffectiveFrom lets a later entry for the same
+// model take over from that date, so older usage keeps its old price.
+type ModelPrice struct {
+	Model         string     `yaml:"model" json:"model"`
+	Input         float64    `yaml:"input" json:"input"`
+	Output        float64    `yaml:"output" json:"output"`
+	CacheRead     float64    `yaml:"cache_read" json:"cache_read"`
+	CacheWrite    float64    `yaml:"cache_write" json:"cache_write"`
+	EffectiveFrom *time.Time `yaml:"effective_from" json:"effective_from,omitempty"`
+}
+
+// effectiveFrom treats an entry without a date as always in force.
+func (p ModelPrice) effectiveFrom() time.Time {
+	if p.EffectiveFrom == nil {
+		return time.Time{}
+	}
+	return *p.EffectiveFrom
+}
+
+// DefaultPriceModel is the pricing entry used for models nothing else matches.
+const DefaultPriceModel = "default"
+
+// defaultPricing is used when agents.yaml has no pricing section.
+func defaultPricing() []ModelPrice {
+	return []ModelPrice{
+		{Model: "anthropic/claude-opus-4-6", Input: 15.0, Output: 75.0, CacheRead: 1.50, CacheWrite: 18.75},
+		{Model: "anthropic/claude-sonnet-4-6", Input: 3.0, Output: 15.0, CacheRead: 0.30, CacheWrite: 3.75},
+		{Model: "google/gemini-2.5-pro", Input: 1.25, Output: 10.0, CacheRead: 0.31, CacheWrite: 1.25},
+		{Model: "google/gemini-2.5-flash", Input: 0.075, Output: 0.30, CacheRead: 0.019, CacheWrite: 0.075},
+		{Model: "opus", Input: 15.0, Output: 75.0, CacheRead: 1.50, CacheWrite: 18.75},
+		{Model: "sonnet", Input: 3.0, Output: 15.0, CacheRead: 0.30, CacheWrite: 3.75},
+		{Model: "haiku", Input: 0.25, Output: 1.25, CacheRead: 0.03, CacheWrite: 0.30},
+		{Model: DefaultPriceModel, Input: 3.0, Output: 15.0, CacheRead: 0.30, CacheWrite: 3.75},
+	}
+}
+
+// BudgetLimits caps spend in USD per calendar period. Zero means no limit.
+type BudgetLimits struct {
+	Daily   float64 `yaml:"daily" json:"daily,omitempty"`
+	Weekly  float64 `yaml:"weekly" json:"weekly,omitempty"`
+	Monthly float64 `yaml:"monthly" json:"monthly,omitempty"`
+}
+
+// Budgets configures spend limits per agent (by ID or name) and per team.
+// An agent over its own budget or its team's is refused new work.
+type Budgets struct {
+	Interval time.Duration           `yaml:"interval" json:"interval"`
+	Agents   map[string]BudgetLimits `yaml:"agents" json:"agents"`
+	Teams    map[string]BudgetLimits `yaml:"teams" json:"teams"`
+}
+
+// StatusThresholds decide an agent's live status from the time since its
+// last session activity: active within ActiveWithin, idle within
+// IdleWithin, offline after that.
+type StatusThresholds struct {
+	ActiveWithin time.Duration `yaml:"active_within" json:"active_within"`
+	IdleWithin   time.Duration `yaml:"idle_within" json:"idle_within"`
+}
+
+// withDefaults fills in 5 minutes active and 30 minutes idle.
+func (t StatusThresholds) withDefaults() StatusThresholds {
+	if t.ActiveWithin <= 0 {
+		t.ActiveWithin = 5 * time.Minute
+	}
+	if t.IdleWithin <= 0 {
+		t.IdleWithin = 30 * time.Minute
+	}
+	return t
+}
+
+// Policy subjects: who a policy rule grants an action to. Task-scoped
+// subjects are judged against the task's current assignee and team.
+const (
+	SubjectSelf     = "self"      // the task's assignee
+	SubjectSubtree  = "subtree"   // any agent above the assignee in the hierarchy
+	SubjectTeamLead = "team_lead" // a lead of the task's team, within that team
+	SubjectAdmin    = "admin"     // admin users and agents listed in policies.admins
+	SubjectUser     = "user"      // any human user key
+	SubjectAnyone   = "anyone"    // every authenticated caller
+)
+
+// Policies maps actions (e.g. "task.transition") to the subjects allowed to
+// perform them. Actions not configured keep their defaults.
+type Policies struct {
+	Admins []string            `yaml:"admins" json:"admins"`
+	Rules  map[string][]string `yaml:"rules" json:"rules"`
+}
+
+// defaultPolicyRules are the built-in rules, overridden per action by
+// agents.yaml.
+func defaultPolicyRules() map[string][]string {
+	return map[string][]string{
+		"task.transition": {SubjectSelf, SubjectSubtree, SubjectAdmin, SubjectUser},
+		"task.comment":    {SubjectSelf, SubjectSubtree, SubjectTeamLead, SubjectAdmin, SubjectUser},
+		"task.update":     {SubjectSelf, SubjectSubtree, SubjectTeamLead, SubjectAdmin, SubjectUser},
+		"task.assign":     {SubjectTeamLead, SubjectAdmin, SubjectUser},
+		"task.delete":     {SubjectAdmin},
+		"comment.delete":  {SubjectAdmin},
+		"keys.manage":     {SubjectAdmin},
+		"webhooks.manage": {SubjectAdmin},
+		"views.manage":    {SubjectAdmin},
+		"labels.manage":   {SubjectAdmin, SubjectUser},
+	}
+}
+
 // AgentsFile is the top-level YAML structure.
 type AgentsFile struct {
-	Name        string       `yaml:"name"`
-	OpenClawDir string       `yaml:"openclaw_dir"`
-	Agents      []*AgentNode `yaml:"agents"`
-	LegacyDirs  map[string][]string `yaml:"legacy_dirs"`
-	Branding    Branding     `yaml:"branding"`
+	Name        string               `yaml:"name"`
+	OpenClawDir string               `yaml:"openclaw_dir"`
+	Agents      []*AgentNode         `yaml:"agents"`
+	LegacyDirs  map[string][]string  `yaml:"legacy_dirs"`
+	Branding    Branding             `yaml:"branding"`
+	Workflows   map[string]*Workflow `yaml:"workflows"`
+	Routing     Routing              `yaml:"routing"`
+	Escalation  Escalation           `yaml:"escalation"`
+	Pricing     []ModelPrice         `yaml:"pricing"`
+	Budgets     Budgets              `yaml:"budgets"`
+	Policies    Policies             `yaml:"policies"`
+	Status      StatusThresholds     `yaml:"status"`
 }
 
 // Agent is a flat agent record (after hierarchy flattening).
@@ -55,6 +320,7 @@ type Agent struct {
 	Team      string
 	TeamColor string
 	IsLead    bool
+	Labels    []string
 	Parent    string
 }
 
@@ -67,6 +333,7 @@ type HierarchyNode struct {
 	Team      string           `json:"team"`
 	TeamColor string           `json:"teamColor"`
 	IsLead    bool             `json:"isLead"`
+	Labels    []string         `json:"labels,omitempty"`
 	Parent    string           `json:"parent,omitempty"`
 	Children  []*HierarchyNode `json:"children,omitempty"`
 }
@@ -82,6 +349,14 @@ type registry struct {
 	legacyDirs  map[string][]string
 	hierarchy   []*HierarchyNode
 	branding    Branding
+	workflows   map[string]Workflow
+	teamFlow    map[string]string
+	routing     Routing
+	escalation  Escalation
+	pricing     []ModelPrice
+	budgets     Budgets
+	policies    Policies
+	status      StatusThresholds
 }
 
 var global = &registry{}
@@ -122,6 +397,7 @@ func flattenNode(node *AgentNode, parent string, out *[]Agent) {
 		Team:      node.Team,
 		TeamColor: node.TeamColor,
 		IsLead:    node.IsLead,
+		Labels:    node.Labels,
 		Parent:    parent,
 	})
 	for _, child := range node.Children {
@@ -139,6 +415,7 @@ func buildHierarchyNode(node *AgentNode, parent string) *HierarchyNode {
 		Team:      node.Team,
 		TeamColor: node.TeamColor,
 		IsLead:    node.IsLead,
+		Labels:    node.Labels,
 		Parent:    parent,
 	}
 	for _, child := range node.Children {
@@ -197,6 +474,74 @@ func (r *registry) load() error {
 		branding.Theme = "dark"
 	}
 
+	workflows, teamFlow, err := buildWorkflows(af.Workflows)
+	if err != nil {
+		return err
+	}
+
+	pricing := af.Pricing
+	if len(pricing) == 0 {
+		pricing = defaultPricing()
+	}
+	for _, p := range pricing {
+		if p.Model == "" {
+			return fmt.Errorf("pricing: entry without a model")
+		}
+	}
+	// Newest first, so the first entry in force at a time is the right one.
+	sort.SliceStable(pricing, func(i, j int) bool {
+		return pricing[i].effectiveFrom().After(pricing[j].effectiveFrom())
+	})
+
+	// Key agent budgets by ID so lookups don't care how the YAML named them.
+	budgets := Budgets{Interval: af.Budgets.Interval, Teams: af.Budgets.Teams, Agents: map[string]BudgetLimits{}}
+	if budgets.Interval <= 0 {
+		budgets.Interval = time.Minute
+	}
+	for key, limits := range af.Budgets.Agents {
+		a, ok := byID[key]
+		if !ok {
+			a, ok = byName[key]
+		}
+		if !ok {
+			return fmt.Errorf("budgets: unknown agent %q", key)
+		}
+		budgets.Agents[a.ID] = limits
+	}
+
+	// Admins are stored by ID; configured rules replace the defaults per action.
+	policies := Policies{Rules: defaultPolicyRules()}
+	for _, key := range af.Policies.Admins {
+		a, ok := byID[key]
+		if !ok {
+			a, ok = byName[key]
+		}
+		if !ok {
+			return fmt.Errorf("policies: unknown admin agent %q", key)
+		}
+		policies.Admins = append(policies.Admins, a.ID)
+	}
+	for action, subjects := range af.Policies.Rules {
+		for _, s := range subjects {
+			switch s {
+			case SubjectSelf, SubjectSubtree, SubjectTeamLead, SubjectAdmin, SubjectUser, SubjectAnyone:
+			default:
+				return fmt.Errorf("policies: %s: unknown subject %q", action, s)
+			}
+		}
+		policies.Rules[action] = subjects
+	}
+
+	status := af.Status.withDefaults()
+	if status.IdleWithin <= status.ActiveWithin {
+		return fmt.Errorf("status: idle_within (%s) must be longer than active_within (%s)", status.IdleWithin, status.ActiveWithin)
+	}
+
+	routing := af.Routing
+	if routing.MaxWIP <= 0 {
+		routing.MaxWIP = 3
+	}
+
 	r.mu.Lock()
 	r.teamName = af.Name
 	r.openClawDir = openClawDir
@@ -206,12 +551,78 @@ func (r *registry) load() error {
 	r.legacyDirs = af.LegacyDirs
 	r.hierarchy = hierarchy
 	r.branding = branding
+	r.workflows = workflows
+	r.teamFlow = teamFlow
+	r.routing = routing
+	r.escalation = af.Escalation.withDefaults()
+	r.pricing = pricing
+	r.budgets = budgets
+	r.policies = policies
+	r.status = status
 	r.mu.Unlock()
 
-	log.Printf("[config] Loaded %d agents from %s (openclaw_dir=%s)", len(flat), abs, openClawDir)
+	log.Printf("[config] Loaded %d agents, %d workflows from %s (openclaw_dir=%s)", len(flat), len(workflows), abs, openClawDir)
 	return nil
 }
 
+// buildWorkflows validates the configured workflows and indexes them by team.
+// The built-in default workflow is added when none named "default" exists.
+func buildWorkflows(cfg map[string]*Workflow) (map[string]Workflow, map[string]string, error) {
+	workflows := make(map[string]Workflow, len(cfg)+1)
+	teamFlow := make(map[string]string)
+
+	for name, wf := range cfg {
+		if wf == nil {
+			continue
+		}
+		w := *wf
+		w.Name = name
+		if len(w.Statuses) == 0 {
+			return nil, nil, fmt.Errorf("workflow %q: no statuses defined", name)
+		}
+		if w.Initial == "" {
+			w.Initial = w.Statuses[0]
+		}
+		if !w.HasStatus(w.Initial) {
+			return nil, nil, fmt.Errorf("workflow %q: initial status %q is not in statuses", name, w.Initial)
+		}
+		if w.Done == "" {
+			w.Done = "done"
+			if !w.HasStatus(w.Done) {
+				w.Done = w.Statuses[len(w.Statuses)-1]
+			}
+		}
+		if !w.HasStatus(w.Done) {
+			return nil, nil, fmt.Errorf("workflow %q: done status %q is not in statuses", name, w.Done)
+		}
+		if w.Transitions == nil {
+			w.Transitions = map[string][]string{}
+		}
+		for from, tos := range w.Transitions {
+			if !w.HasStatus(from) {
+				return nil, nil, fmt.Errorf("workflow %q: transition from unknown status %q", name, from)
+			}
+			for _, to := range tos {
+				if !w.HasStatus(to) {
+					return nil, nil, fmt.Errorf("workflow %q: transition %s → %s targets unknown status", name, from, to)
+				}
+			}
+		}
+		for _, team := range w.Teams {
+			if other, ok := teamFlow[team]; ok {
+				return nil, nil, fmt.Errorf("team %q is assigned to both %q and %q workflows", team, other, name)
+			}
+			teamFlow[team] = name
+		}
+		workflows[name] = w
+	}
+
+	if _, ok := workflows[DefaultWorkflowName]; !ok {
+		workflows[DefaultWorkflowName] = defaultWorkflow()
+	}
+	return workflows, teamFlow, nil
+}
+
 // watchSIGHUP listens for SIGHUP and reloads config.
 func (r *registry) watchSIGHUP() {
 	ch := make(chan os.Signal, 1)
@@ -300,3 +711,288 @@ func GetHierarchy() []*HierarchyNode {
 	copy(cp, global.hierarchy)
 	return cp
 }
+
+// GetWorkflows returns all configured workflows sorted by name.
+func GetWorkflows() []Workflow {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	if len(global.workflows) == 0 {
+		return []Workflow{defaultWorkflow()}
+	}
+	out := make([]Workflow, 0, len(global.workflows))
+	for _, wf := range global.workflows {
+		out = append(out, wf)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// GetWorkflow returns the workflow with the given name (or nil).
+func GetWorkflow(name string) *Workflow {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	if wf, ok := global.workflows[name]; ok {
+		return &wf
+	}
+	if name == DefaultWorkflowName {
+		wf := defaultWorkflow()
+		return &wf
+	}
+	return nil
+}
+
+// DoneStatuses returns the done status of every workflow, sorted.
+func DoneStatuses() []string {
+	return workflowStatuses(func(wf Workflow) []string { return []string{wf.Done} })
+}
+
+// WorkingStatuses returns the working status of every workflow, sorted. A
+// task in one of them is being worked on.
+func WorkingStatuses() []string {
+	return workflowStatuses(func(wf Workflow) []string { return []string{wf.Working()} })
+}
+
+// ActiveStatuses returns the initial and working statuses of every
+// workflow, sorted: the open work queued for or held by an agent.
+func ActiveStatuses() []string {
+	return workflowStatuses(func(wf Workflow) []string { return []string{wf.Initial, wf.Working()} })
+}
+
+// workflowStatuses collects the statuses pick returns for every workflow,
+// without duplicates, sorted.
+func workflowStatuses(pick func(Workflow) []string) []string {
+	seen := make(map[string]bool)
+	for _, wf := range GetWorkflows() {
+		for _, s := range pick(wf) {
+			seen[s] = true
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for s := range seen {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// GetWorkflowForTeam returns the workflow used by the given team, falling
+// back to the default workflow.
+func GetWorkflowForTeam(team string) Workflow {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	if name, ok := global.teamFlow[team]; ok {
+		return global.workflows[name]
+	}
+	if wf, ok := global.workflows[DefaultWorkflowName]; ok {
+		return wf
+	}
+	return defaultWorkflow()
+}
+
+// GetRouting returns the auto-assignment routing configuration.
+func GetRouting() Routing {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	cp := global.routing
+	cp.Rules = make([]RoutingRule, len(global.routing.Rules))
+	copy(cp.Rules, global.routing.Rules)
+	if cp.MaxWIP <= 0 {
+		cp.MaxWIP = 3
+	}
+	return cp
+}
+
+// GetAncestors returns the chain of managers above the agent with the given
+// ID or name, nearest first. Unknown agents have no ancestors.
+func GetAncestors(idOrName string) []Agent {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	a, ok := global.agentByID[idOrName]
+	if !ok {
+		a, ok = global.agentByName[idOrName]
+	}
+	if !ok {
+		return nil
+	}
+	var chain []Agent
+	seen := map[string]bool{a.ID: true}
+	for a.Parent != "" {
+		p, ok := global.agentByName[a.Parent]
+		if !ok || seen[p.ID] {
+			break
+		}
+		seen[p.ID] = true
+		chain = append(chain, *p)
+		a = p
+	}
+	return chain
+}
+
+// IsDescendant reports whether agent sits somewhere below ancestor in the
+// hierarchy. Both may be given as ID or name.
+func IsDescendant(agent, ancestor string) bool {
+	for _, a := range GetAncestors(agent) {
+		if a.ID == ancestor || a.Name == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// GetEscalation returns the escalation thresholds, with defaults applied.
+func GetEscalation() Escalation {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	// withDefaults builds fresh maps, so callers can't mutate the registry.
+	return global.escalation.withDefaults()
+}
+
+// GetTeamLead returns the lead of a team, preferring the one highest in the
+// hierarchy when a team has several.
+func GetTeamLead(team string) *Agent {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	var best *Agent
+	bestDepth := 0
+	for i := range global.agents {
+		a := &global.agents[i]
+		if !a.IsLead || !strings.EqualFold(a.Team, team) {
+			continue
+		}
+		depth := 0
+		for p := a.Parent; p != "" && depth < len(global.agents); depth++ {
+			pa, ok := global.agentByName[p]
+			if !ok {
+				break
+			}
+			p = pa.Parent
+		}
+		if best == nil || depth < bestDepth {
+			best, bestDepth = a, depth
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	cp := *best
+	return &cp
+}
+
+// GetPricing returns the model pricing table, newest entries first.
+func GetPricing() []ModelPrice {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	if len(global.pricing) == 0 {
+		return defaultPricing()
+	}
+	cp := make([]ModelPrice, len(global.pricing))
+	copy(cp, global.pricing)
+	return cp
+}
+
+// PriceFor returns the rates in force for model at the given time: an exact
+// model match first, then the longest substring match, then the "default"
+// entry. Entries not yet effective at that time are skipped.
+func PriceFor(model string, at time.Time) ModelPrice {
+	global.mu.RLock()
+	table := global.pricing
+	global.mu.RUnlock()
+	if len(table) == 0 {
+		table = defaultPricing()
+	}
+
+	var exact, partial, fallback *ModelPrice
+	for i := range table {
+		p := &table[i]
+		if p.effectiveFrom().After(at) {
+			continue
+		}
+		switch {
+		case p.Model == model:
+			if exact == nil {
+				exact = p
+			}
+		case p.Model == DefaultPriceModel:
+			if fallback == nil {
+				fallback = p
+			}
+		case model != "" && strings.Contains(model, p.Model):
+			if partial == nil || len(p.Model) > len(partial.Model) {
+				partial = p
+			}
+		}
+	}
+	switch {
+	case exact != nil:
+		return *exact
+	case partial != nil:
+		return *partial
+	case fallback != nil:
+		return *fallback
+	}
+	return ModelPrice{Model: DefaultPriceModel, Input: 3.0, Output: 15.0, CacheRead: 0.30, CacheWrite: 3.75}
+}
+
+// GetStatusThresholds returns the agent status thresholds, with defaults
+// applied.
+func GetStatusThresholds() StatusThresholds {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	return global.status.withDefaults()
+}
+
+// GetBudgets returns the configured budgets, agent budgets keyed by agent ID.
+func GetBudgets() Budgets {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	cp := Budgets{
+		Interval: global.budgets.Interval,
+		Agents:   make(map[string]BudgetLimits, len(global.budgets.Agents)),
+		Teams:    make(map[string]BudgetLimits, len(global.budgets.Teams)),
+	}
+	if cp.Interval <= 0 {
+		cp.Interval = time.Minute
+	}
+	for k, v := range global.budgets.Agents {
+		cp.Agents[k] = v
+	}
+	for k, v := range global.budgets.Teams {
+		cp.Teams[k] = v
+	}
+	return cp
+}
+
+// GetPolicies returns the access policies, with defaults for actions
+// agents.yaml doesn't mention.
+func GetPolicies() Policies {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	cp := Policies{
+		Admins: append([]string{}, global.policies.Admins...),
+		Rules:  defaultPolicyRules(),
+	}
+	for action, subjects := range global.policies.Rules {
+		cp.Rules[action] = append([]string{}, subjects...)
+	}
+	return cp
+}
+
+// IsPolicyAdmin reports whether the agent with the given ID or name is
+// listed in policies.admins.
+func IsPolicyAdmin(idOrName string) bool {
+	global.mu.RLock()
+	defer global.mu.RUnlock()
+	a, ok := global.agentByID[idOrName]
+	if !ok {
+		a, ok = global.agentByName[idOrName]
+	}
+	if !ok {
+		return false
+	}
+	for _, id := range global.policies.Admins {
+		if id == a.ID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/config"
+	"github.com/alghanim/agentboard/backend/db"
+)
+
+// statusDebounce is how long an agent's session files must be quiet before
+// its status is recomputed, so a burst of writes costs one pass.
+const statusDebounce = 2 * time.Second
+
+// statusResyncInterval is how often every agent is recomputed regardless,
+// to catch missed notifications and agents added by a config reload.
+const statusResyncInterval = 5 * time.Minute
+
+// agentStatusTracker keeps each agent's live status current. Writes to an
+// agent's session files (reported by the transcript watcher) trigger a
+// debounced recompute, and a timer fires when the agent's last activity
+// ages past the active or idle threshold. Only changes are broadcast, and
+// every transition is recorded in agent_status_history.
+type agentStatusTracker struct {
+	refreshMu sync.Mutex // serialises recomputes
+
+	mu       sync.Mutex
+	hub      broadcaster
+	statuses map[string]OCAgentStatus // by agent ID
+	debounce map[string]*time.Timer
+	expiry   map[string]*time.Timer
+
+	// recorded is each agent's latest history row; guarded by refreshMu.
+	recorded map[string]statusChange
+}
+
+// statusChange is an agent entering a status at a point in time.
+type statusChange struct {
+	Status string
+	At     time.Time
+}
+
+var agentStatuses = &agentStatusTracker{
+	statuses: make(map[string]OCAgentStatus),
+	debounce: make(map[string]*time.Timer),
+	expiry:   make(map[string]*time.Timer),
+	recorded: make(map[string]statusChange),
+}
+
+// StartAgentStatusTracker runs in a goroutine. It records every agent's
+// status in the agents table and broadcasts agent_status_changed when one
+// changes.
+func StartAgentStatusTracker(hub broadcaster) {
+	agentStatuses.mu.Lock()
+	agentStatuses.hub = hub
+	agentStatuses.mu.Unlock()
+
+	agentStatuses.refreshAll()
+
+	ticker := time.NewTicker(statusResyncInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		agentStatuses.refreshAll()
+	}
+}
+
+// touch schedules a recompute of the agent's status once its files have
+// been quiet for statusDebounce.
+func (t *agentStatusTracker) touch(agentID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hub == nil {
+		return // not started; the initial pass will pick it up
+	}
+	if timer, ok := t.debounce[agentID]; ok {
+		timer.Reset(statusDebounce)
+		return
+	}
+	t.debounce[agentID] = time.AfterFunc(statusDebounce, func() {
+		t.mu.Lock()
+		delete(t.debounce, agentID)
+		t.mu.Unlock()
+		t.refresh(agentID)
+	})
+}
+
+func (t *agentStatusTracker) refreshAll() {
+	for _, ca := range config.GetAgents() {
+		t.refresh(ca.ID)
+	}
+}
+
+// refresh recomputes one agent's status, persists it and broadcasts it if it
+// changed, and schedules the next threshold crossing.
+func (t *agentStatusTracker) refresh(agentID string) {
+	t.refreshMu.Lock()
+	defer t.refreshMu.Unlock()
+
+	ca := config.GetAgentByID(agentID)
+	if ca == nil {
+		return
+	}
+	s := getOCAgentStatus(agentFromConfig(*ca))
+
+	t.mu.Lock()
+	prev, seen := t.statuses[agentID]
+	t.statuses[agentID] = s
+	t.scheduleExpiry(agentID, s)
+	hub := t.hub
+	t.mu.Unlock()
+
+	if seen && prev.Status == s.Status && prev.LastActive.Equal(s.LastActive) {
+		return
+	}
+	t.recordHistory(agentID, s)
+	var lastActive interface{}
+	if !s.LastActive.IsZero() {
+		lastActive = s.LastActive
+	}
+	if _, err := db.DB.Exec(`UPDATE agents SET status = $1, last_active = $2 WHERE id = $3`,
+		dbAgentStatus(s.Status), lastActive, agentID); err != nil {
+		log.Printf("[status] update %s: %v", agentID, err)
+	}
+
+	if seen && prev.Status != s.Status {
+		hub.Broadcast("agent_status_changed", map[string]interface{}{
+			"agent_id":   agentID,
+			"old_status": prev.Status,
+			"new_status": s.Status,
+			"agent":      s,
+		}, agentTopics(agentID)...)
+	}
+}
+
+// scheduleExpiry arms a timer for when the agent's status will next change
+// without any new activity. Callers hold t.mu.
+func (t *agentStatusTracker) scheduleExpiry(agentID string, s OCAgentStatus) {
+	if timer, ok := t.expiry[agentID]; ok {
+		timer.Stop()
+		delete(t.expiry, agentID)
+	}
+	th := config.GetStatusThresholds()
+	var at time.Time
+	switch s.Status {
+	case "active":
+		at = s.LastActive.Add(th.ActiveWithin)
+	case "idle":
+		at = s.LastActive.Add(th.IdleWithin)
+	default:
+		return
+	}
+	t.expiry[agentID] = time.AfterFunc(time.Until(at)+time.Second, func() {
+		t.refresh(agentID)
+	})
+}
+
+// recordHistory appends the transitions since the agent's last recorded
+// status to agent_status_history. They are dated from its last activity, so
+// history stays accurate across debounce delays and server restarts.
+func (t *agentStatusTracker) recordHistory(agentID string, s OCAgentStatus) {
+	last, ok := t.recorded[agentID]
+	if !ok {
+		err := db.DB.QueryRow(`
+			SELECT status, changed_at FROM agent_status_history
+			WHERE agent_id = $1 ORDER BY changed_at DESC, id DESC LIMIT 1`, agentID).
+			Scan(&last.Status, &last.At)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("[status] load history %s: %v", agentID, err)
+			return
+		}
+	}
+
+	for _, c := range statusTimeline(s, config.GetStatusThresholds()) {
+		if c.Status == last.Status || !c.At.After(last.At) {
+			continue
+		}
+		if _, err := db.DB.Exec(`
+			INSERT INTO agent_status_history (agent_id, status, previous_status, changed_at)
+			VALUES ($1, $2, NULLIF($3, ''), $4)`,
+			agentID, c.Status, last.Status, c.At); err != nil {
+			log.Printf("[status] record history %s: %v", agentID, err)
+			break
+		}
+		last = c
+	}
+	t.recorded[agentID] = last
+}
+
+// statusTimeline returns the statuses an agent has passed through since its
+// last activity, up to its current one: active at the activity, idle once
+// that is ActiveWithin old, offline once it is IdleWithin old.
+func statusTimeline(s OCAgentStatus, th config.StatusThresholds) []statusChange {
+	if s.LastActive.IsZero() {
+		return []statusChange{{Status: "offline", At: time.Now()}}
+	}
+	timeline := []statusChange{
+		{Status: "active", At: s.LastActive},
+		{Status: "idle", At: s.LastActive.Add(th.ActiveWithin)},
+		{Status: "offline", At: s.LastActive.Add(th.IdleWithin)},
+	}
+	for i, c := range timeline {
+		if c.Status == s.Status {
+			return timeline[:i+1]
+		}
+	}
+	return timeline
+}
+
+// dbAgentStatus maps a live status onto the agents.status column.
+func dbAgentStatus(status string) string {
+	switch status {
+	case "active":
+		return "online"
+	case "idle":
+		return "idle"
+	default:
+		return "offline"
+	}
+}
@@ -19,10 +19,10 @@ func (h *AnalyticsHandler) GetOverview(w http.ResponseWriter, r *http.Request) {
 	db.DB.QueryRow(`SELECT COUNT(*) FROM tasks`).Scan(&totalTasks)
 
 	var completedThisWeek int
-	db.DB.QueryRow(`SELECT COUNT(*) FROM tasks WHERE status = 'done' AND completed_at >= date_trunc('week', NOW())`).Scan(&completedThisWeek)
+	db.DB.QueryRow(`SELECT COUNT(*) FROM tasks WHERE status IN ` + doneStatusesSQL() + ` AND completed_at >= date_trunc('week', NOW())`).Scan(&completedThisWeek)
 
 	var avgHours *float64
-	db.DB.QueryRow(`SELECT AVG(EXTRACT(EPOCH FROM (completed_at - created_at)) / 3600) FROM tasks WHERE status = 'done' AND completed_at IS NOT NULL`).Scan(&avgHours)
+	db.DB.QueryRow(`SELECT AVG(EXTRACT(EPOCH FROM (completed_at - created_at)) / 3600) FROM tasks WHERE status IN ` + doneStatusesSQL() + ` AND completed_at IS NOT NULL`).Scan(&avgHours)
 
 	var agentsActiveToday int
 	db.DB.QueryRow(`SELECT COUNT(DISTINCT agent_id) FROM activity_log WHERE created_at >= CURRENT_DATE`).Scan(&agentsActiveToday)
@@ -54,12 +54,12 @@ func (h *AnalyticsHandler) GetAgentAnalytics(w http.ResponseWriter, r *http.Requ
 		LEFT JOIN (
 			SELECT assignee, COUNT(*) AS cnt,
 				AVG(EXTRACT(EPOCH FROM (completed_at - created_at)) / 3600) AS avg_hours
-			FROM tasks WHERE status = 'done' AND completed_at IS NOT NULL
+			FROM tasks WHERE status IN ` + doneStatusesSQL() + ` AND completed_at IS NOT NULL
 			GROUP BY assignee
 		) done ON done.assignee = a.id
 		LEFT JOIN (
 			SELECT assignee, COUNT(*) AS cnt
-			FROM tasks WHERE status IN ('progress', 'todo')
+			FROM tasks WHERE status IN ` + activeStatusesSQL() + `
 			GROUP BY assignee
 		) prog ON prog.assignee = a.id
 		ORDER BY completed DESC
@@ -111,7 +111,7 @@ func (h *AnalyticsHandler) GetThroughput(w http.ResponseWriter, r *http.Request)
 		FROM generate_series(NOW() - ($1 || ' days')::interval, NOW(), '1 day') d
 		LEFT JOIN (
 			SELECT completed_at::date AS day, COUNT(*) AS cnt
-			FROM tasks WHERE status = 'done' AND completed_at >= NOW() - ($1 || ' days')::interval
+			FROM tasks WHERE status IN `+doneStatusesSQL()+` AND completed_at >= NOW() - ($1 || ' days')::interval
 			GROUP BY day
 		) t ON t.day = d::date
 		ORDER BY date
@@ -147,8 +147,8 @@ func (h *AnalyticsHandler) GetTeamAnalytics(w http.ResponseWriter, r *http.Reque
 	rows, err := db.DB.Query(`
 		SELECT
 			COALESCE(a.team, 'unassigned') AS team,
-			COUNT(*) FILTER (WHERE t.status = 'done') AS completed,
-			COUNT(*) FILTER (WHERE t.status IN ('progress', 'todo')) AS in_progress,
+			COUNT(*) FILTER (WHERE t.status IN ` + doneStatusesSQL() + `) AS completed,
+			COUNT(*) FILTER (WHERE t.status IN ` + activeStatusesSQL() + `) AS in_progress,
 			COUNT(*) AS total
 		FROM tasks t
 		LEFT JOIN agents a ON a.id = t.assignee
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alghanim/agentboard/backend/config"
+	"github.com/alghanim/agentboard/backend/db"
+	"github.com/alghanim/agentboard/backend/models"
+)
+
+// assignCandidate is one agent considered during auto-assignment.
+type assignCandidate struct {
+	Agent   string   `json:"agent"`
+	Score   int      `json:"score"`
+	WIP     int      `json:"wip"`
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons"`
+}
+
+// assignDecision is the outcome of auto-assignment, kept for the activity log.
+type assignDecision struct {
+	Assignee    string            `json:"assignee"`
+	Rule        string            `json:"rule,omitempty"`
+	Explanation string            `json:"explanation"`
+	Candidates  []assignCandidate `json:"candidates"`
+}
+
+// autoAssign picks an agent for task. The first routing rule that matches
+// the task's labels and priority narrows the pool to a team and may prefer
+// a subtree of the hierarchy; agents are then ranked by live status, skills,
+// lead status and current WIP. Agents at the WIP limit or over budget are
+// skipped.
+func autoAssign(task models.Task) (*assignDecision, error) {
+	routing := config.GetRouting()
+
+	var rule *config.RoutingRule
+	for i := range routing.Rules {
+		if ruleMatches(routing.Rules[i], task) {
+			rule = &routing.Rules[i]
+			break
+		}
+	}
+
+	team := ""
+	if task.Team != nil {
+		team = *task.Team
+	}
+	wanted := append([]string{}, task.Labels...)
+	var preferUnder string
+	if rule != nil {
+		if rule.Team != "" {
+			team = rule.Team
+		}
+		preferUnder = rule.PreferChildrenOf
+		wanted = append(wanted, rule.Skills...)
+	}
+
+	wip, err := wipCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	decision := &assignDecision{Candidates: []assignCandidate{}}
+	if rule != nil {
+		decision.Rule = rule.Name
+	}
+
+	var skipped []string
+	for _, ca := range config.GetAgents() {
+		if team != "" && !strings.EqualFold(ca.Team, team) {
+			continue
+		}
+		c := assignCandidate{Agent: ca.ID, WIP: wip[ca.ID], Reasons: []string{}}
+		if c.WIP >= routing.MaxWIP {
+			skipped = append(skipped, fmt.Sprintf("%s (WIP %d/%d)", ca.ID, c.WIP, routing.MaxWIP))
+			continue
+		}
+		if overBudget(ca.ID) != "" {
+			skipped = append(skipped, ca.ID+" (over budget)")
+			continue
+		}
+
+		c.Status = getOCAgentStatus(agentFromConfig(ca)).Status
+		switch c.Status {
+		case "idle":
+			c.Score += 3
+		case "active":
+			c.Score += 2
+		}
+		c.Reasons = append(c.Reasons, c.Status)
+
+		if preferUnder != "" && config.IsDescendant(ca.ID, preferUnder) {
+			c.Score += 4
+			c.Reasons = append(c.Reasons, "under "+preferUnder)
+		}
+
+		if matched := matchSkills(ca, wanted); len(matched) > 0 {
+			c.Score += 2 * len(matched)
+			c.Reasons = append(c.Reasons, "skills "+strings.Join(matched, ","))
+		}
+
+		// Leads coordinate; leave them for work nobody else can take.
+		if ca.IsLead {
+			c.Score -= 2
+			c.Reasons = append(c.Reasons, "lead")
+		}
+
+		c.Score -= c.WIP
+		c.Reasons = append(c.Reasons, fmt.Sprintf("WIP %d", c.WIP))
+		decision.Candidates = append(decision.Candidates, c)
+	}
+
+	sort.SliceStable(decision.Candidates, func(i, j int) bool {
+		a, b := decision.Candidates[i], decision.Candidates[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.WIP != b.WIP {
+			return a.WIP < b.WIP
+		}
+		return a.Agent < b.Agent
+	})
+
+	var scope string
+	switch {
+	case rule != nil && team != "":
+		scope = fmt.Sprintf("rule %q → team %s", rule.Name, team)
+	case rule != nil:
+		scope = fmt.Sprintf("rule %q", rule.Name)
+	case team != "":
+		scope = "task team " + team
+	default:
+		scope = "no matching rule, all agents"
+	}
+
+	if len(decision.Candidates) == 0 {
+		decision.Explanation = scope + ": no eligible agent"
+		if len(skipped) > 0 {
+			decision.Explanation += "; skipped: " + strings.Join(skipped, ", ")
+		}
+		return decision, nil
+	}
+
+	best := decision.Candidates[0]
+	decision.Assignee = best.Agent
+	decision.Explanation = fmt.Sprintf("%s; picked %s (score %d: %s) from %d candidate(s)",
+		scope, best.Agent, best.Score, strings.Join(best.Reasons, ", "), len(decision.Candidates))
+	if len(skipped) > 0 {
+		decision.Explanation += "; skipped: " + strings.Join(skipped, ", ")
+	}
+	return decision, nil
+}
+
+// ruleMatches reports whether a routing rule's conditions hold for task.
+func ruleMatches(rule config.RoutingRule, task models.Task) bool {
+	if rule.Priority != "" && !strings.EqualFold(rule.Priority, task.Priority) {
+		return false
+	}
+	if rule.Label == "" {
+		return true
+	}
+	for _, l := range task.Labels {
+		if strings.EqualFold(l, rule.Label) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSkills returns the wanted names the agent has a skill (or label) for.
+func matchSkills(ca config.Agent, wanted []string) []string {
+	if len(wanted) == 0 {
+		return nil
+	}
+	have := map[string]bool{}
+	for _, s := range discoverSkills(ca) {
+		have[strings.ToLower(s.Name)] = true
+	}
+	for _, l := range ca.Labels {
+		have[strings.ToLower(l)] = true
+	}
+
+	var matched []string
+	seen := map[string]bool{}
+	for _, w := range wanted {
+		k := strings.ToLower(w)
+		if have[k] && !seen[k] {
+			seen[k] = true
+			matched = append(matched, w)
+		}
+	}
+	return matched
+}
+
+// wipCounts returns the number of unfinished, started-or-queued tasks per
+// assignee, keyed by agent ID when the assignee (an ID or a display name) is
+// a configured agent.
+func wipCounts() (map[string]int, error) {
+	rows, err := db.DB.Query(`
+		SELECT assignee, COUNT(*) FROM tasks
+		WHERE assignee IS NOT NULL AND assignee <> '' AND status <> 'backlog' AND status NOT IN ` + doneStatusesSQL() + `
+		GROUP BY assignee`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var assignee string
+		var n int
+		if err := rows.Scan(&assignee, &n); err != nil {
+			return nil, err
+		}
+		if ca := resolveAgent(assignee); ca != nil {
+			assignee = ca.ID
+		}
+		counts[assignee] += n
+	}
+	return counts, rows.Err()
+}
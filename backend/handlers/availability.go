@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/config"
+	"github.com/alghanim/agentboard/backend/db"
+	"github.com/alghanim/agentboard/backend/models"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// GetStatusHistory handles GET /api/agents/{id}/status-history
+// Optional since/until (RFC 3339) and limit (default 100, max 1000); newest
+// first.
+func (h *AgentHandler) GetStatusHistory(w http.ResponseWriter, r *http.Request) {
+	ca := resolveAgent(mux.Vars(r)["id"])
+	if ca == nil {
+		respondError(w, http.StatusNotFound, "Agent not found")
+		return
+	}
+
+	q := r.URL.Query()
+	limit := 100
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 && v <= 1000 {
+		limit = v
+	}
+	query := `SELECT id, agent_id, status, previous_status, changed_at
+		FROM agent_status_history WHERE agent_id = $1`
+	args := []interface{}{ca.ID}
+	for _, p := range []struct{ param, cond string }{{"since", ">="}, {"until", "<"}} {
+		s := q.Get(p.param)
+		if s == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, p.param+" must be an RFC 3339 timestamp")
+			return
+		}
+		args = append(args, t)
+		query += " AND changed_at " + p.cond + " $" + strconv.Itoa(len(args))
+	}
+	args = append(args, limit)
+	query += " ORDER BY changed_at DESC, id DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	history := []models.AgentStatusChange{}
+	for rows.Next() {
+		var c models.AgentStatusChange
+		if err := rows.Scan(&c.ID, &c.AgentID, &c.Status, &c.PreviousStatus, &c.ChangedAt); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		history = append(history, c)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(w, http.StatusInternalServerError, "row iteration error: "+err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, history)
+}
+
+// AvailabilityDay is the share of one day an agent spent in each status, as
+// percentages of the part of the day inside the report window.
+type AvailabilityDay struct {
+	Date       string  `json:"date"`
+	ActivePct  float64 `json:"active_pct"`
+	IdlePct    float64 `json:"idle_pct"`
+	OfflinePct float64 `json:"offline_pct"`
+}
+
+// AvailabilityGap is a stretch of uninterrupted idle time.
+type AvailabilityGap struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Seconds int64     `json:"seconds"`
+}
+
+// AgentAvailability is one agent's availability over the report window.
+// Heatmap[weekday][hour] is the percentage of that hour spent active across
+// the window, Sunday first, in the report's time zone.
+type AgentAvailability struct {
+	AgentID        string            `json:"agent_id"`
+	Name           string            `json:"name"`
+	Team           string            `json:"team"`
+	ActivePct      float64           `json:"active_pct"`
+	Days           []AvailabilityDay `json:"days"`
+	LongestIdleGap *AvailabilityGap  `json:"longest_idle_gap"`
+	Heatmap        [7][24]float64    `json:"heatmap"`
+}
+
+// GetAvailability handles GET /api/analytics/availability
+// Params: days (default 30, max 365), agent, team, tz (IANA name, default
+// UTC). Built from agent_status_history.
+func (h *AnalyticsHandler) GetAvailability(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	days := 30
+	if v, err := strconv.Atoi(q.Get("days")); err == nil && v > 0 && v <= 365 {
+		days = v
+	}
+	loc := time.UTC
+	if tz := q.Get("tz"); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "unknown time zone: "+tz)
+			return
+		}
+		loc = l
+	}
+	agentFilter, teamFilter := q.Get("agent"), q.Get("team")
+
+	var agents []config.Agent
+	for _, ca := range config.GetAgents() {
+		if agentFilter != "" && !strings.EqualFold(ca.ID, agentFilter) && !strings.EqualFold(ca.Name, agentFilter) {
+			continue
+		}
+		if teamFilter != "" && !strings.EqualFold(ca.Team, teamFilter) {
+			continue
+		}
+		agents = append(agents, ca)
+	}
+
+	end := time.Now().In(loc)
+	y, m, d := end.Date()
+	start := time.Date(y, m, d-days+1, 0, 0, 0, 0, loc)
+
+	ids := make([]string, len(agents))
+	for i, ca := range agents {
+		ids[i] = ca.ID
+	}
+	// Each agent's status at the start of the window, then every change in it.
+	rows, err := db.DB.Query(`
+		SELECT agent_id, status, changed_at FROM (
+			SELECT DISTINCT ON (agent_id) agent_id, status, $2::timestamptz AS changed_at
+			FROM agent_status_history
+			WHERE agent_id = ANY($1) AND changed_at <= $2
+			ORDER BY agent_id, changed_at DESC, id DESC
+		) initial
+		UNION ALL
+		SELECT agent_id, status, changed_at FROM agent_status_history
+		WHERE agent_id = ANY($1) AND changed_at > $2
+		ORDER BY agent_id, changed_at`, pq.Array(ids), start)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	changes := make(map[string][]statusChange)
+	for rows.Next() {
+		var agentID string
+		var c statusChange
+		if err := rows.Scan(&agentID, &c.Status, &c.At); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		changes[agentID] = append(changes[agentID], c)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(w, http.StatusInternalServerError, "row iteration error: "+err.Error())
+		return
+	}
+
+	reports := make([]AgentAvailability, 0, len(agents))
+	for _, ca := range agents {
+		rep := availability(changes[ca.ID], start, end, loc)
+		rep.AgentID, rep.Name, rep.Team = ca.ID, ca.Name, ca.Team
+		reports = append(reports, rep)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"from":       start,
+		"to":         end,
+		"timezone":   loc.String(),
+		"thresholds": config.GetStatusThresholds(),
+		"agents":     reports,
+	})
+}
+
+// availability summarises an agent's status changes (oldest first) over
+// [start, end). Time before the first change counts as offline.
+func availability(changes []statusChange, start, end time.Time, loc *time.Location) AgentAvailability {
+	type totals struct{ active, idle, offline time.Duration }
+	var overall totals
+	byDay := make(map[string]*totals)
+	var slotActive, slotSeen [7][24]time.Duration
+
+	var gap, longest *AvailabilityGap
+	closeGap := func(at time.Time) {
+		if gap == nil {
+			return
+		}
+		gap.End = at
+		gap.Seconds = int64(at.Sub(gap.Start).Seconds())
+		if longest == nil || gap.Seconds > longest.Seconds {
+			longest = gap
+		}
+		gap = nil
+	}
+
+	status, from := "offline", start
+	segment := func(to time.Time) {
+		if status != "idle" {
+			closeGap(from)
+		} else if gap == nil {
+			gap = &AvailabilityGap{Start: from}
+		}
+		// Split at hour boundaries for the daily totals and the heatmap.
+		for cur := from; cur.Before(to); {
+			lc := cur.In(loc)
+			next := time.Date(lc.Year(), lc.Month(), lc.Day(), lc.Hour()+1, 0, 0, 0, loc)
+			if next.After(to) {
+				next = to
+			}
+			dur := next.Sub(cur)
+			day := lc.Format("2006-01-02")
+			t := byDay[day]
+			if t == nil {
+				t = &totals{}
+				byDay[day] = t
+			}
+			wd, hr := lc.Weekday(), lc.Hour()
+			slotSeen[wd][hr] += dur
+			switch status {
+			case "active":
+				t.active += dur
+				overall.active += dur
+				slotActive[wd][hr] += dur
+			case "idle":
+				t.idle += dur
+				overall.idle += dur
+			default:
+				t.offline += dur
+				overall.offline += dur
+			}
+			cur = next
+		}
+	}
+	for _, c := range changes {
+		at := c.At
+		if at.Before(start) {
+			at = start
+		}
+		if at.After(end) {
+			break
+		}
+		segment(at)
+		status, from = c.Status, at
+	}
+	segment(end)
+	closeGap(end)
+
+	pct := func(part, whole time.Duration) float64 {
+		if whole <= 0 {
+			return 0
+		}
+		return math.Round(float64(part)/float64(whole)*1000) / 10
+	}
+
+	rep := AgentAvailability{
+		ActivePct:      pct(overall.active, overall.active+overall.idle+overall.offline),
+		Days:           []AvailabilityDay{},
+		LongestIdleGap: longest,
+	}
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		t := byDay[key]
+		if t == nil {
+			continue
+		}
+		whole := t.active + t.idle + t.offline
+		rep.Days = append(rep.Days, AvailabilityDay{
+			Date:       key,
+			ActivePct:  pct(t.active, whole),
+			IdlePct:    pct(t.idle, whole),
+			OfflinePct: pct(t.offline, whole),
+		})
+	}
+	for wd := range slotSeen {
+		for hr := range slotSeen[wd] {
+			rep.Heatmap[wd][hr] = pct(slotActive[wd][hr], slotSeen[wd][hr])
+		}
+	}
+	return rep
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/config"
+	"github.com/alghanim/agentboard/backend/db"
+	"github.com/alghanim/agentboard/backend/models"
+	"github.com/alghanim/agentboard/backend/websocket"
+)
+
+// budgetWarnAt is the share of a budget that triggers budget_warning.
+const budgetWarnAt = 0.8
+
+type BudgetHandler struct{}
+
+// budgetStatus is the spend against one limit for the current period.
+type budgetStatus struct {
+	Scope     string    `json:"scope"` // "agent" or "team"
+	Name      string    `json:"name"`
+	Period    string    `json:"period"` // "daily", "weekly" or "monthly"
+	Limit     float64   `json:"limit"`
+	Spent     float64   `json:"spent"`
+	Remaining float64   `json:"remaining"`
+	Percent   float64   `json:"percent"`
+	State     string    `json:"state"` // "ok", "warning" or "exceeded"
+	Since     time.Time `json:"since"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// key identifies the status's budget window, for de-duplicating alerts.
+func (b budgetStatus) key() string {
+	return fmt.Sprintf("%s:%s:%s:%s", b.Scope, b.Name, b.Period, b.Since.Format("2006-01-02"))
+}
+
+var budgetCache struct {
+	sync.Mutex
+	at       time.Time
+	statuses []budgetStatus
+}
+
+// GetBudgets handles GET /api/budgets?agent=<id>&team=<name>
+// Returns spend and remaining headroom for every configured budget.
+func (h *BudgetHandler) GetBudgets(w http.ResponseWriter, r *http.Request) {
+	agent, team := r.URL.Query().Get("agent"), r.URL.Query().Get("team")
+	if ca := config.GetAgent(agent); ca != nil {
+		agent = ca.ID
+	}
+
+	out := []budgetStatus{}
+	for _, b := range currentBudgets() {
+		if agent != "" && (b.Scope != "agent" || b.Name != agent) {
+			continue
+		}
+		if team != "" && (b.Scope != "team" || b.Name != team) {
+			continue
+		}
+		out = append(out, b)
+	}
+	respondJSON(w, http.StatusOK, out)
+}
+
+// StartBudgetWatcher runs in a goroutine and raises budget_warning at 80% and
+// budget_exceeded at 100% of each budget, once per budget period.
+func StartBudgetWatcher(hub broadcaster) {
+	for {
+		time.Sleep(config.GetBudgets().Interval)
+		for _, b := range computeBudgets() {
+			var action string
+			switch b.State {
+			case "warning":
+				action = "budget_warning"
+			case "exceeded":
+				action = "budget_exceeded"
+			default:
+				continue
+			}
+
+			// The activity log doubles as the record of what was already sent,
+			// so a restart doesn't repeat alerts.
+			var sent bool
+			if err := db.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM activity_log WHERE action = $1 AND details->>'key' = $2)`,
+				action, b.key()).Scan(&sent); err != nil {
+				log.Printf("[budgets] check %s: %v", b.key(), err)
+				continue
+			}
+			if sent {
+				continue
+			}
+
+			agentID := "system"
+			topics := []string{websocket.Topic("team", b.Name)}
+			if b.Scope == "agent" {
+				agentID = b.Name
+				topics = agentTopics(b.Name)
+			}
+			logActivity(agentID, action, "", map[string]string{
+				"key":     b.key(),
+				"scope":   b.Scope,
+				"name":    b.Name,
+				"period":  b.Period,
+				"limit":   fmt.Sprintf("%.2f", b.Limit),
+				"spent":   fmt.Sprintf("%.2f", b.Spent),
+				"percent": fmt.Sprintf("%.0f", b.Percent),
+			})
+			hub.Broadcast(action, b, topics...)
+		}
+	}
+}
+
+// overBudget reports why an agent may not take new work, or "" if it may.
+func overBudget(agentID string) string {
+	ca := config.GetAgentByID(agentID)
+	if ca == nil {
+		ca = config.GetAgent(agentID)
+	}
+	if ca == nil {
+		return ""
+	}
+	for _, b := range currentBudgets() {
+		if b.State != "exceeded" {
+			continue
+		}
+		if b.Scope == "agent" && b.Name == ca.ID {
+			return fmt.Sprintf("%s is over its %s budget: $%.2f of $%.2f spent; resets %s",
+				ca.ID, b.Period, b.Spent, b.Limit, b.ResetsAt.Format(time.RFC3339))
+		}
+		if b.Scope == "team" && b.Name == ca.Team {
+			return fmt.Sprintf("team %s is over its %s budget: $%.2f of $%.2f spent; resets %s",
+				ca.Team, b.Period, b.Spent, b.Limit, b.ResetsAt.Format(time.RFC3339))
+		}
+	}
+	return ""
+}
+
+// refuseOverBudget writes a 403 and returns true if the agent is over budget.
+func refuseOverBudget(w http.ResponseWriter, agentID string) bool {
+	reason := overBudget(agentID)
+	if reason == "" {
+		return false
+	}
+	respondError(w, http.StatusForbidden, "Refusing new work: "+reason)
+	return true
+}
+
+// refuseReassignOverBudget is refuseOverBudget for an update that gives a
+// task a new assignee. Keeping or clearing the assignee is always allowed.
+func refuseReassignOverBudget(w http.ResponseWriter, before, after models.Task) bool {
+	assignee := models.PtrToNullString(after.Assignee).String
+	if assignee == "" || assignee == models.PtrToNullString(before.Assignee).String {
+		return false
+	}
+	return refuseOverBudget(w, assignee)
+}
+
+// currentBudgets returns budget statuses at most a few seconds old, so the
+// per-request checks don't each walk the token index.
+func currentBudgets() []budgetStatus {
+	budgetCache.Lock()
+	defer budgetCache.Unlock()
+	if time.Since(budgetCache.at) > 10*time.Second {
+		budgetCache.statuses = computeBudgets()
+		budgetCache.at = time.Now()
+	}
+	return budgetCache.statuses
+}
+
+// computeBudgets measures current-period spend from the token index against
+// every configured budget. Periods follow GetCostSummary: the day, the week
+// starting Sunday, and the calendar month, in server-local time.
+func computeBudgets() []budgetStatus {
+	budgets := config.GetBudgets()
+	if len(budgets.Agents) == 0 && len(budgets.Teams) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	weekStart := dayStart.AddDate(0, 0, -int(now.Weekday()))
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	periods := []struct {
+		name         string
+		start, reset time.Time
+		limit        func(config.BudgetLimits) float64
+	}{
+		{"daily", dayStart, dayStart.AddDate(0, 0, 1), func(l config.BudgetLimits) float64 { return l.Daily }},
+		{"weekly", weekStart, weekStart.AddDate(0, 0, 7), func(l config.BudgetLimits) float64 { return l.Weekly }},
+		{"monthly", monthStart, monthStart.AddDate(0, 1, 0), func(l config.BudgetLimits) float64 { return l.Monthly }},
+	}
+	earliest := weekStart
+	if monthStart.Before(earliest) {
+		earliest = monthStart
+	}
+
+	teamOf := map[string]string{}
+	for _, ca := range config.GetAgents() {
+		teamOf[ca.ID] = ca.Team
+	}
+
+	// spend[scope+name][period index]
+	spend := map[string]*[3]float64{}
+	add := func(key string, i int, cost float64) {
+		s, ok := spend[key]
+		if !ok {
+			s = &[3]float64{}
+			spend[key] = s
+		}
+		s[i] += cost
+	}
+	dirAgent := map[string]string{}
+	for _, m := range tokenMessages() {
+		if m.Timestamp.Before(earliest) {
+			continue
+		}
+		agentID, ok := dirAgent[m.AgentID]
+		if !ok {
+			agentID = agentIDForDir(m.AgentID)
+			dirAgent[m.AgentID] = agentID
+		}
+		cost := m.Cost()
+		for i, p := range periods {
+			if m.Timestamp.Before(p.start) {
+				continue
+			}
+			add("agent:"+agentID, i, cost)
+			if team := teamOf[agentID]; team != "" {
+				add("team:"+team, i, cost)
+			}
+		}
+	}
+
+	var out []budgetStatus
+	emit := func(scope, name string, limits config.BudgetLimits) {
+		s := spend[scope+":"+name]
+		for i, p := range periods {
+			limit := p.limit(limits)
+			if limit <= 0 {
+				continue
+			}
+			b := budgetStatus{Scope: scope, Name: name, Period: p.name, Limit: limit, Since: p.start, ResetsAt: p.reset, State: "ok"}
+			if s != nil {
+				b.Spent = s[i]
+			}
+			b.Remaining = limit - b.Spent
+			if b.Remaining < 0 {
+				b.Remaining = 0
+			}
+			b.Percent = b.Spent / limit * 100
+			switch {
+			case b.Spent >= limit:
+				b.State = "exceeded"
+			case b.Spent >= limit*budgetWarnAt:
+				b.State = "warning"
+			}
+			out = append(out, b)
+		}
+	}
+	for id, limits := range budgets.Agents {
+		emit("agent", id, limits)
+	}
+	for team, limits := range budgets.Teams {
+		emit("team", team, limits)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Scope != out[j].Scope {
+			return out[i].Scope < out[j].Scope
+		}
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Since.After(out[j].Since)
+	})
+	return out
+}
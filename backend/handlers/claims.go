@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/auth"
+	"github.com/alghanim/agentboard/backend/config"
+	"github.com/alghanim/agentboard/backend/db"
+	"github.com/alghanim/agentboard/backend/models"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultLease = 15 * time.Minute
+	minLease     = time.Minute
+	maxLease     = 2 * time.Hour
+)
+
+// ClaimTask handles POST /api/tasks/claim
+// Atomically picks the most urgent unassigned task the calling agent
+// (X-Agent-ID) is eligible for, assigns it, moves it into progress and grants
+// a lease. Body (optional): {"labels": [...], "lease_seconds": 900}.
+// Responds 204 when nothing is available.
+func (h *TaskHandler) ClaimTask(w http.ResponseWriter, r *http.Request) {
+	ca, ok := callingAgent(w, r)
+	if !ok {
+		return
+	}
+	if refuseOverBudget(w, ca.ID) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+	var data struct {
+		Labels       []string `json:"labels"`
+		LeaseSeconds int      `json:"lease_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil && err != io.EOF {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	labels := data.Labels
+	if labels == nil {
+		labels = ca.Labels
+	}
+	lease := leaseDuration(data.LeaseSeconds)
+
+	wf := config.GetWorkflowForTeam(ca.Team)
+	working := wf.Working()
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED lets concurrent claimers each take a different task
+	// instead of queueing on (and then double-claiming) the same row.
+	var labelFilter interface{}
+	if len(labels) > 0 {
+		labelFilter = pq.Array(labels)
+	}
+	var id, fromStatus string
+	err = tx.QueryRow(`
+		SELECT t.id, t.status FROM tasks t
+		WHERE t.status = $1
+		  AND (t.assignee IS NULL OR t.assignee = '' OR t.assignee = $2)
+		  AND (t.team IS NULL OR t.team = '' OR t.team = $3)
+		  AND ($4::text[] IS NULL OR t.labels IS NULL OR cardinality(t.labels) = 0 OR t.labels && $4::text[])
+		  AND (t.lease_owner IS NULL OR t.lease_expires_at < NOW())
+		  AND NOT EXISTS (
+		      SELECT 1 FROM task_dependencies d JOIN tasks b ON b.id = d.depends_on
+		      WHERE d.task_id = t.id AND b.status NOT IN `+doneStatusesSQL()+`)
+		ORDER BY `+priorityRankSQL+`, t.created_at ASC
+		LIMIT 1
+		FOR UPDATE OF t SKIP LOCKED`,
+		wf.Initial, ca.ID, ca.Team, labelFilter).Scan(&id, &fromStatus)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE tasks SET assignee = $1, status = $2, lease_owner = $1,
+		       lease_expires_at = NOW() + make_interval(secs => $3)
+		WHERE id = $4`, ca.ID, working, lease.Seconds(), id); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	db.DB.Exec(`UPDATE agents SET current_task_id = $1::uuid WHERE id = $2`, id, ca.ID)
+	recordTransition(id, fromStatus, working, ca.ID, "Claimed")
+
+	task, err := loadTask(id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logActivity(ca.ID, "task_claimed", id, map[string]string{
+		"lease_expires_at": task.LeaseExpires.UTC().Format(time.RFC3339),
+	})
+	h.Hub.Broadcast("task_claimed", task, taskEventTopics(task)...)
+
+	w.Header().Set("ETag", taskETag(task.Version))
+	respondJSON(w, http.StatusOK, task)
+}
+
+// HeartbeatTask handles POST /api/tasks/{id}/heartbeat
+// Renews the calling agent's lease. Body (optional): {"lease_seconds": 900}.
+// Returns 409 if the agent no longer holds the lease.
+func (h *TaskHandler) HeartbeatTask(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	ca, ok := callingAgent(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+	var data struct {
+		LeaseSeconds int `json:"lease_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil && err != io.EOF {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var expires time.Time
+	err := db.DB.QueryRow(`
+		UPDATE tasks SET lease_expires_at = NOW() + make_interval(secs => $1)
+		WHERE id = $2 AND lease_owner = $3
+		RETURNING lease_expires_at`,
+		leaseDuration(data.LeaseSeconds).Seconds(), id, ca.ID).Scan(&expires)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusConflict, "Lease not held by "+ca.ID+"; claim a new task")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"task_id":          id,
+		"lease_owner":      ca.ID,
+		"lease_expires_at": expires,
+	})
+}
+
+// StartLeaseReaper runs in a goroutine and returns tasks whose lease has
+// expired to their workflow's initial status.
+func StartLeaseReaper(hub broadcaster) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		reapExpiredLeases(hub)
+	}
+}
+
+func reapExpiredLeases(hub broadcaster) {
+	rows, err := db.DB.Query(`
+		SELECT id, status, lease_owner, team
+		FROM tasks
+		WHERE lease_owner IS NOT NULL AND lease_expires_at < NOW()`)
+	if err != nil {
+		log.Printf("[leases] query expired leases: %v", err)
+		return
+	}
+	type expired struct {
+		id, status, owner string
+		team              sql.NullString
+	}
+	var list []expired
+	for rows.Next() {
+		var e expired
+		if err := rows.Scan(&e.id, &e.status, &e.owner, &e.team); err == nil {
+			list = append(list, e)
+		}
+	}
+	rows.Close()
+
+	for _, e := range list {
+		// The assignee is the lease owner being evicted, so the task's team
+		// (not the assignee's) decides the workflow.
+		target := workflowFor(models.NullStringToPtr(e.team), nil).Initial
+
+		// Re-check expiry so a heartbeat that raced us wins.
+		result, err := db.DB.Exec(`
+			UPDATE tasks SET status = $1, assignee = NULL, lease_owner = NULL, lease_expires_at = NULL
+			WHERE id = $2 AND lease_owner = $3 AND lease_expires_at < NOW()`,
+			target, e.id, e.owner)
+		if err != nil {
+			log.Printf("[leases] release task %s: %v", e.id, err)
+			continue
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+
+		db.DB.Exec(`UPDATE agents SET current_task_id = NULL WHERE id = $1 AND current_task_id = $2::uuid`, e.owner, e.id)
+		if e.status != target {
+			recordTransition(e.id, e.status, target, "system", "Lease expired")
+		}
+		logActivity(e.owner, "lease_expired", e.id, map[string]string{"from": e.status, "to": target})
+		hub.Broadcast("lease_expired", map[string]string{"task_id": e.id, "agent": e.owner, "status": target},
+			append(taskTopicsByID(e.id), agentTopics(e.owner)...)...)
+	}
+}
+
+// announceLeaseRelease logs and broadcasts lease_released when an update
+// took the lease from its holder, e.g. by reassigning the task.
+func (h *TaskHandler) announceLeaseRelease(before, after models.Task, actor string) {
+	if before.LeaseOwner == nil || after.LeaseOwner != nil {
+		return
+	}
+	owner := *before.LeaseOwner
+	db.DB.Exec(`UPDATE agents SET current_task_id = NULL WHERE id = $1 AND current_task_id = $2::uuid`, owner, after.ID)
+	logActivity(actor, "lease_released", after.ID, map[string]string{"agent": owner})
+	h.Hub.Broadcast("lease_released", map[string]string{"task_id": after.ID, "agent": owner},
+		append(taskEventTopics(after), agentTopics(owner)...)...)
+}
+
+// callingAgent resolves the caller (agent token, or X-Agent-ID without auth)
+// to a configured agent, writing an error response if it can't.
+func callingAgent(w http.ResponseWriter, r *http.Request) (*config.Agent, bool) {
+	if id, ok := auth.FromRequest(r); ok && id.Kind != auth.KindAgent {
+		respondError(w, http.StatusForbidden, "This endpoint needs an agent token")
+		return nil, false
+	}
+	agentID := getAgentFromContext(r)
+	if agentID == "system" {
+		respondError(w, http.StatusBadRequest, "X-Agent-ID header is required")
+		return nil, false
+	}
+	ca := config.GetAgentByID(agentID)
+	if ca == nil {
+		ca = config.GetAgent(agentID)
+	}
+	if ca == nil {
+		respondError(w, http.StatusNotFound, "Unknown agent "+agentID)
+		return nil, false
+	}
+	return ca, true
+}
+
+// leaseDuration converts a requested lease length to a clamped duration.
+func leaseDuration(seconds int) time.Duration {
+	if seconds <= 0 {
+		return defaultLease
+	}
+	d := time.Duration(seconds) * time.Second
+	if d < minLease {
+		return minLease
+	}
+	if d > maxLease {
+		return maxLease
+	}
+	return d
+}
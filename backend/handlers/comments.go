@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"net/http"
 
+	"github.com/alghanim/agentboard/backend/auth"
 	"github.com/alghanim/agentboard/backend/db"
 	"github.com/alghanim/agentboard/backend/models"
 	"github.com/alghanim/agentboard/backend/websocket"
@@ -51,6 +53,13 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	comment.TaskID = taskID
+	if !allowOnTask(w, r, "task.comment", taskID) {
+		return
+	}
+	// An authenticated caller comments as itself.
+	if id, ok := auth.FromRequest(r); ok {
+		comment.Author = id.Actor()
+	}
 
 	err := db.DB.QueryRow(
 		`INSERT INTO comments (task_id, author, content) VALUES ($1, $2, $3)
@@ -64,7 +73,7 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logActivity(comment.Author, "comment_added", taskID, map[string]string{"comment_id": comment.ID})
-	h.Hub.Broadcast("comment_added", comment)
+	h.Hub.Broadcast("comment_added", comment, taskTopicsByID(taskID)...)
 
 	respondJSON(w, http.StatusCreated, comment)
 }
@@ -73,13 +82,30 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	if _, err := db.DB.Exec(`DELETE FROM comments WHERE id = $1`, id); err != nil {
+	if auth.Enabled() {
+		var taskID string
+		if err := db.DB.QueryRow(`SELECT task_id FROM comments WHERE id = $1`, id).Scan(&taskID); err != nil {
+			respondError(w, http.StatusNotFound, "Comment not found")
+			return
+		}
+		if !allowOnTask(w, r, "comment.delete", taskID) {
+			return
+		}
+	}
+
+	var taskID sql.NullString
+	err := db.DB.QueryRow(`DELETE FROM comments WHERE id = $1 RETURNING task_id`, id).Scan(&taskID)
+	if err != nil && err != sql.ErrNoRows {
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	logActivity(getAgentFromContext(r), "comment_deleted", "", map[string]string{"comment_id": id})
-	h.Hub.Broadcast("comment_deleted", map[string]string{"id": id})
+	var topics []string
+	if taskID.Valid {
+		topics = taskTopicsByID(taskID.String)
+	}
+	h.Hub.Broadcast("comment_deleted", map[string]string{"id": id, "task_id": taskID.String}, topics...)
 
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Comment deleted"})
 }
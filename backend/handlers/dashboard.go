@@ -15,8 +15,8 @@ func (h *DashboardHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 
 	db.DB.QueryRow(`SELECT COUNT(*) FROM agents`).Scan(&stats.TotalAgents)
 	db.DB.QueryRow(`SELECT COUNT(*) FROM agents WHERE status = 'online'`).Scan(&stats.OnlineAgents)
-	db.DB.QueryRow(`SELECT COUNT(*) FROM tasks WHERE status NOT IN ('done', 'backlog')`).Scan(&stats.ActiveTasks)
-	db.DB.QueryRow(`SELECT COUNT(*) FROM tasks WHERE status = 'done'`).Scan(&stats.CompletedTasks)
+	db.DB.QueryRow(`SELECT COUNT(*) FROM tasks WHERE status <> 'backlog' AND status NOT IN ` + doneStatusesSQL()).Scan(&stats.ActiveTasks)
+	db.DB.QueryRow(`SELECT COUNT(*) FROM tasks WHERE status IN ` + doneStatusesSQL()).Scan(&stats.CompletedTasks)
 
 	var totalTasks int
 	db.DB.QueryRow(`SELECT COUNT(*) FROM tasks`).Scan(&totalTasks)
@@ -35,7 +35,7 @@ func (h *DashboardHandler) GetTeamStats(w http.ResponseWriter, r *http.Request)
 			COUNT(a.id) as total_agents,
 			COUNT(CASE WHEN a.status = 'online' THEN 1 END) as online_agents,
 			COUNT(t.id) as active_tasks,
-			COUNT(CASE WHEN t.status = 'done' THEN 1 END) as completed_tasks
+			COUNT(CASE WHEN t.status IN ` + doneStatusesSQL() + ` THEN 1 END) as completed_tasks
 		FROM agents a
 		LEFT JOIN tasks t ON t.assignee = a.id
 		GROUP BY a.team
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/alghanim/agentboard/backend/db"
+	"github.com/alghanim/agentboard/backend/models"
+	"github.com/alghanim/agentboard/backend/websocket"
+
+	"github.com/gorilla/mux"
+)
+
+// GetDependencies handles GET /api/tasks/{id}/dependencies
+// Returns the tasks this one is blocked by and the tasks it is blocking.
+func (h *TaskHandler) GetDependencies(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var exists bool
+	if err := db.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM tasks WHERE id = $1)`, id).Scan(&exists); err != nil || !exists {
+		respondError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	blockedBy, err := queryDependencies(`
+		SELECT t.id, t.title, t.status, d.created_by, d.created_at
+		FROM task_dependencies d JOIN tasks t ON t.id = d.depends_on
+		WHERE d.task_id = $1
+		ORDER BY d.created_at ASC`, id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	blocking, err := queryDependencies(`
+		SELECT t.id, t.title, t.status, d.created_by, d.created_at
+		FROM task_dependencies d JOIN tasks t ON t.id = d.task_id
+		WHERE d.depends_on = $1
+		ORDER BY d.created_at ASC`, id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"blocked_by": blockedBy,
+		"blocking":   blocking,
+	})
+}
+
+// AddDependency handles POST /api/tasks/{id}/dependencies
+// Body: {"depends_on": "<task id>"}. Rejects edges that would create a cycle.
+func (h *TaskHandler) AddDependency(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+	var data struct {
+		DependsOn string `json:"depends_on"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if data.DependsOn == "" {
+		respondError(w, http.StatusBadRequest, "depends_on is required")
+		return
+	}
+	if data.DependsOn == id {
+		respondError(w, http.StatusBadRequest, "A task cannot depend on itself")
+		return
+	}
+	if !allowOnTask(w, r, "task.update", id) {
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	// Serialise graph changes so two concurrent inserts can't close a cycle together.
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('task_dependencies'))`); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var found int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM tasks WHERE id IN ($1, $2)`, id, data.DependsOn).Scan(&found); err != nil || found != 2 {
+		respondError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+
+	// Adding id → depends_on creates a cycle if id is already reachable from depends_on.
+	var cycle bool
+	err = tx.QueryRow(`
+		WITH RECURSIVE reach(task_id) AS (
+			SELECT depends_on FROM task_dependencies WHERE task_id = $1
+			UNION
+			SELECT d.depends_on FROM task_dependencies d JOIN reach r ON d.task_id = r.task_id
+		)
+		SELECT EXISTS(SELECT 1 FROM reach WHERE task_id = $2)`,
+		data.DependsOn, id).Scan(&cycle)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if cycle {
+		respondError(w, http.StatusConflict, "Dependency would create a cycle")
+		return
+	}
+
+	actor := getAgentFromContext(r)
+	result, err := tx.Exec(`
+		INSERT INTO task_dependencies (task_id, depends_on, created_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`, id, data.DependsOn, actor)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondJSON(w, http.StatusOK, map[string]string{"message": "Dependency already exists"})
+		return
+	}
+
+	logActivity(actor, "dependency_added", id, map[string]string{"depends_on": data.DependsOn})
+	h.Hub.Broadcast("dependency_added", map[string]string{"task_id": id, "depends_on": data.DependsOn},
+		append(taskTopicsByID(id), websocket.Topic("task", data.DependsOn))...)
+
+	respondJSON(w, http.StatusCreated, map[string]string{"task_id": id, "depends_on": data.DependsOn})
+}
+
+// RemoveDependency handles DELETE /api/tasks/{id}/dependencies/{depends_on}
+func (h *TaskHandler) RemoveDependency(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, dependsOn := vars["id"], vars["depends_on"]
+	if !allowOnTask(w, r, "task.update", id) {
+		return
+	}
+
+	result, err := db.DB.Exec(`DELETE FROM task_dependencies WHERE task_id = $1 AND depends_on = $2`, id, dependsOn)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondError(w, http.StatusNotFound, "Dependency not found")
+		return
+	}
+
+	actor := getAgentFromContext(r)
+	logActivity(actor, "dependency_removed", id, map[string]string{"depends_on": dependsOn})
+	h.Hub.Broadcast("dependency_removed", map[string]string{"task_id": id, "depends_on": dependsOn},
+		append(taskTopicsByID(id), websocket.Topic("task", dependsOn))...)
+
+	// Removing the last open blocker frees the task just like completing it would.
+	h.unblockIfReady(id, dependsOn, actor)
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Dependency removed"})
+}
+
+// openBlockers returns the blockers of a task that are not yet done.
+func openBlockers(taskID string) ([]models.TaskDependency, error) {
+	return queryDependencies(`
+		SELECT t.id, t.title, t.status, d.created_by, d.created_at
+		FROM task_dependencies d JOIN tasks t ON t.id = d.depends_on
+		WHERE d.task_id = $1 AND t.status NOT IN `+doneStatusesSQL()+`
+		ORDER BY d.created_at ASC`, taskID)
+}
+
+// refuseBlocked answers 409 with the open blockers if a task has any, for a
+// move into its workflow's working status. It reports whether it wrote a response.
+func refuseBlocked(w http.ResponseWriter, taskID string) bool {
+	blockers, err := openBlockers(taskID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return true
+	}
+	if len(blockers) > 0 {
+		respondJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":      fmt.Sprintf("Task has %d open blocker(s)", len(blockers)),
+			"blocked_by": blockers,
+		})
+		return true
+	}
+	return false
+}
+
+// releaseDependents moves every blocked task whose last open blocker was
+// doneID back to todo. Called after a task reaches done.
+func (h *TaskHandler) releaseDependents(doneID, actor string) {
+	rows, err := db.DB.Query(`SELECT task_id FROM task_dependencies WHERE depends_on = $1`, doneID)
+	if err != nil {
+		log.Printf("releaseDependents(%s): %v", doneID, err)
+		return
+	}
+	var dependents []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			dependents = append(dependents, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range dependents {
+		h.unblockIfReady(id, doneID, actor)
+	}
+}
+
+// unblockIfReady moves a blocked task to todo if it has no open blockers left.
+func (h *TaskHandler) unblockIfReady(taskID, cause, actor string) {
+	var status string
+	var team, assignee sql.NullString
+	if err := db.DB.QueryRow(`SELECT status, team, assignee FROM tasks WHERE id = $1`, taskID).
+		Scan(&status, &team, &assignee); err != nil || status != "blocked" {
+		return
+	}
+	open, err := openBlockers(taskID)
+	if err != nil || len(open) > 0 {
+		return
+	}
+
+	target := "todo"
+	if wf := workflowFor(models.NullStringToPtr(team), models.NullStringToPtr(assignee)); !wf.HasStatus(target) {
+		target = wf.Initial
+	}
+
+	// Guard on status so a concurrent transition isn't overwritten.
+	result, err := db.DB.Exec(`UPDATE tasks SET status = $1 WHERE id = $2 AND status = 'blocked'`, target, taskID)
+	if err != nil {
+		log.Printf("unblock task %s: %v", taskID, err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return
+	}
+
+	recordTransition(taskID, "blocked", target, actor, "All blockers resolved")
+	logActivity(actor, "task_unblocked", taskID, map[string]string{"resolved_by": cause, "to": target})
+	h.Hub.Broadcast("task_unblocked", map[string]string{"task_id": taskID, "status": target, "resolved_by": cause},
+		taskTopicsByID(taskID)...)
+}
+
+// recordTransition appends a status change to task_history.
+func recordTransition(taskID, from, to, changedBy, note string) {
+	if _, err := db.DB.Exec(`
+		INSERT INTO task_history (task_id, from_status, to_status, changed_by, changed_at, note)
+		VALUES ($1, $2, $3, $4, NOW(), NULLIF($5, ''))`,
+		taskID, from, to, changedBy, note); err != nil {
+		log.Printf("record transition for task %s: %v", taskID, err)
+	}
+}
+
+func queryDependencies(query string, args ...interface{}) ([]models.TaskDependency, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deps := []models.TaskDependency{}
+	for rows.Next() {
+		var d models.TaskDependency
+		var createdBy sql.NullString
+		if err := rows.Scan(&d.TaskID, &d.Title, &d.Status, &createdBy, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		d.CreatedBy = models.NullStringToPtr(createdBy)
+		deps = append(deps, d)
+	}
+	return deps, rows.Err()
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/config"
+	"github.com/alghanim/agentboard/backend/db"
+	"github.com/alghanim/agentboard/backend/models"
+)
+
+// StartEscalationScheduler runs in a goroutine and escalates stuck and overdue
+// tasks up the hierarchy. The interval is re-read from config every pass so
+// a SIGHUP reload takes effect without a restart.
+func StartEscalationScheduler(hub broadcaster) {
+	for {
+		time.Sleep(config.GetEscalation().Interval)
+		runEscalations(hub)
+	}
+}
+
+func runEscalations(hub broadcaster) {
+	esc := config.GetEscalation()
+
+	stuck, err := queryTasks(`SELECT ` + taskColumns + ` FROM tasks WHERE status IN ` + workingStatusesSQL())
+	if err != nil {
+		log.Printf("[escalation] query stuck tasks: %v", err)
+	}
+	for _, t := range stuck {
+		th := esc.StuckThreshold(t.Priority)
+		escalate(hub, t, "stuck", t.UpdatedAt, time.Since(t.UpdatedAt), th)
+	}
+
+	overdue, err := queryTasks(`SELECT ` + taskColumns + ` FROM tasks
+		WHERE due_date IS NOT NULL AND due_date < NOW() AND status NOT IN ` + doneStatusesSQL())
+	if err != nil {
+		log.Printf("[escalation] query overdue tasks: %v", err)
+	}
+	for _, t := range overdue {
+		th := esc.OverdueThreshold(t.Priority)
+		escalate(hub, t, "overdue", *t.DueDate, time.Since(*t.DueDate), th)
+	}
+}
+
+// escalate notifies every level whose threshold has passed and that hasn't
+// been notified yet for this episode. since identifies the episode (the
+// last update for stuck tasks, the due date for overdue ones), so a task
+// that moves again or gets a new due date can escalate afresh.
+func escalate(hub broadcaster, task models.Task, kind string, since time.Time, elapsed time.Duration, th config.EscalationThreshold) {
+	if elapsed < th.Lead {
+		return
+	}
+	lead := escalationLead(task)
+	if lead == nil {
+		return
+	}
+	targets := []*config.Agent{lead}
+	if th.Parent > 0 && elapsed >= th.Parent {
+		if parents := config.GetAncestors(lead.ID); len(parents) > 0 {
+			targets = append(targets, &parents[0])
+		}
+	}
+
+	for i, target := range targets {
+		level := i + 1
+		result, err := db.DB.Exec(`
+			INSERT INTO task_escalations (task_id, kind, level, since, notified)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (task_id, kind, level, since) DO NOTHING`,
+			task.ID, kind, level, since, target.ID)
+		if err != nil {
+			log.Printf("[escalation] record %s escalation for task %s: %v", kind, task.ID, err)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+
+		threshold := th.Lead
+		if level == 2 {
+			threshold = th.Parent
+		}
+		content := escalationMessage(task, kind, level, target, elapsed, threshold)
+
+		var c models.Comment
+		c.TaskID, c.Author, c.Content = task.ID, "system", content
+		if err := db.DB.QueryRow(
+			`INSERT INTO comments (task_id, author, content) VALUES ($1, $2, $3)
+			 RETURNING id, created_at`,
+			c.TaskID, c.Author, c.Content).Scan(&c.ID, &c.CreatedAt); err != nil {
+			log.Printf("[escalation] comment on task %s: %v", task.ID, err)
+		} else {
+			hub.Broadcast("comment_added", c, taskEventTopics(task)...)
+		}
+
+		assignee := ""
+		if task.Assignee != nil {
+			assignee = *task.Assignee
+		}
+		logActivity("system", "task_escalated", task.ID, map[string]string{
+			"kind":     kind,
+			"level":    fmt.Sprint(level),
+			"notified": target.ID,
+			"assignee": assignee,
+			"elapsed":  elapsed.Round(time.Minute).String(),
+		})
+		hub.Broadcast("task_escalated", map[string]interface{}{
+			"task_id":  task.ID,
+			"title":    task.Title,
+			"kind":     kind,
+			"level":    level,
+			"notified": target.ID,
+			"assignee": assignee,
+		}, append(taskEventTopics(task), agentTopics(target.ID)...)...)
+	}
+}
+
+// escalationLead returns the first lead above the assignee, or the task
+// team's lead when the task is unassigned or the assignee isn't configured.
+func escalationLead(task models.Task) *config.Agent {
+	if task.Assignee != nil && *task.Assignee != "" {
+		for _, a := range config.GetAncestors(*task.Assignee) {
+			if a.IsLead {
+				a := a
+				return &a
+			}
+		}
+	}
+	if task.Team != nil && *task.Team != "" {
+		if lead := config.GetTeamLead(*task.Team); lead != nil &&
+			(task.Assignee == nil || lead.ID != *task.Assignee) {
+			return lead
+		}
+	}
+	return nil
+}
+
+func escalationMessage(task models.Task, kind string, level int, target *config.Agent, elapsed, threshold time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "@%s escalation", target.Name)
+	if level > 1 {
+		b.WriteString(" (level 2)")
+	}
+	b.WriteString(": ")
+	switch kind {
+	case "stuck":
+		fmt.Fprintf(&b, "no progress for %s", elapsed.Round(time.Minute))
+	case "overdue":
+		fmt.Fprintf(&b, "overdue by %s", elapsed.Round(time.Minute))
+	}
+	fmt.Fprintf(&b, " (threshold %s for %s priority)", threshold, task.Priority)
+	if task.Assignee != nil && *task.Assignee != "" {
+		fmt.Fprintf(&b, ", assigned to %s", *task.Assignee)
+	} else {
+		b.WriteString(", unassigned")
+	}
+	b.WriteString(".")
+	return b.String()
+}
+
+func queryTasks(query string, args ...interface{}) ([]models.Task, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []models.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
@@ -3,10 +3,95 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
+	"github.com/alghanim/agentboard/backend/auth"
+	"github.com/alghanim/agentboard/backend/config"
 	"github.com/alghanim/agentboard/backend/db"
+	"github.com/alghanim/agentboard/backend/models"
+	"github.com/alghanim/agentboard/backend/websocket"
+
+	"github.com/lib/pq"
 )
 
+// doneStatusesSQL lists every workflow's done status for SQL, as in
+// "status IN " + doneStatusesSQL(). A task in one of them is complete.
+func doneStatusesSQL() string {
+	return statusesSQL(config.DoneStatuses())
+}
+
+// workingStatusesSQL lists every workflow's working status for SQL. A task
+// in one of them is being worked on.
+func workingStatusesSQL() string {
+	return statusesSQL(config.WorkingStatuses())
+}
+
+// activeStatusesSQL lists every workflow's initial and working statuses for
+// SQL: the open work queued for or held by an agent.
+func activeStatusesSQL() string {
+	return statusesSQL(config.ActiveStatuses())
+}
+
+// statusesSQL quotes statuses as a parenthesized SQL list.
+func statusesSQL(statuses []string) string {
+	quoted := make([]string, len(statuses))
+	for i, s := range statuses {
+		quoted[i] = pq.QuoteLiteral(s)
+	}
+	return "(" + strings.Join(quoted, ", ") + ")"
+}
+
+// broadcaster is the part of websocket.Hub that background jobs need.
+type broadcaster interface {
+	Broadcast(msgType string, payload interface{}, topics ...string)
+}
+
+// taskTopics returns the broadcast topics for an event about a task: the
+// task, its assignee, and its team and the assignee's team.
+func taskTopics(id string, assignee, team *string) []string {
+	topics := []string{websocket.Topic("task", id)}
+	if team != nil && *team != "" {
+		topics = append(topics, websocket.Topic("team", *team))
+	}
+	if assignee != nil && *assignee != "" {
+		topics = append(topics, agentTopics(*assignee)...)
+	}
+	return topics
+}
+
+// taskEventTopics is taskTopics for a task in its current state, plus the
+// saved views it is in now or was in before the change.
+func taskEventTopics(t models.Task) []string {
+	return append(taskTopics(t.ID, t.Assignee, t.Team), savedViews.topics(t)...)
+}
+
+// taskTopicsByID is taskEventTopics for a task loaded from the database. A
+// task that no longer exists gets its own topic and its former views'.
+func taskTopicsByID(id string) []string {
+	t, err := loadTask(id)
+	if err != nil {
+		return append([]string{websocket.Topic("task", id)}, savedViews.forget(id)...)
+	}
+	return taskEventTopics(t)
+}
+
+// agentTopics returns the broadcast topics for an agent (by ID or name): the
+// agent and its team.
+func agentTopics(agent string) []string {
+	ca := config.GetAgentByID(agent)
+	if ca == nil {
+		ca = config.GetAgent(agent)
+	}
+	if ca == nil {
+		return []string{websocket.Topic("agent", agent)}
+	}
+	topics := []string{websocket.Topic("agent", ca.ID)}
+	if ca.Team != "" {
+		topics = append(topics, websocket.Topic("team", ca.Team))
+	}
+	return topics
+}
+
 func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
 	data, err := json.Marshal(payload)
 	if err != nil {
@@ -23,7 +108,15 @@ func respondError(w http.ResponseWriter, code int, message string) {
 	respondJSON(w, code, map[string]string{"error": message})
 }
 
+// getAgentFromContext returns the acting agent or user. With auth enabled it
+// comes from the bearer token; otherwise from the X-Agent-ID header.
 func getAgentFromContext(r *http.Request) string {
+	if id, ok := auth.FromRequest(r); ok {
+		return id.Actor()
+	}
+	if auth.Enabled() {
+		return "system"
+	}
 	if agent := r.Header.Get("X-Agent-ID"); agent != "" {
 		return agent
 	}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/config"
+	"github.com/alghanim/agentboard/backend/db"
+)
+
+// sessionIngestConsumer names the ingester's rows in jsonl_offsets.
+const sessionIngestConsumer = "sessions"
+
+// sessionIdleAfter is how long a session file can go unwritten before the
+// session is considered completed rather than running.
+const sessionIdleAfter = 30 * time.Minute
+
+// StartSessionIngester runs in a goroutine and incrementally loads OpenClaw
+// session transcripts into agent_sessions and agent_metrics.
+func StartSessionIngester() {
+	// The first pass rebuilds task metrics for all history; later passes
+	// only touch the last couple of days.
+	ingestSessions(time.Time{})
+
+	ticker := time.NewTicker(60 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		ingestSessions(time.Now().AddDate(0, 0, -1))
+	}
+}
+
+func ingestSessions(taskMetricsSince time.Time) {
+	offsets, err := loadOffsets(sessionIngestConsumer)
+	if err != nil {
+		log.Printf("[ingest] load offsets: %v", err)
+		return
+	}
+	running := map[string]bool{}
+	if rows, err := db.DB.Query(`SELECT session_key FROM agent_sessions WHERE status = 'running'`); err == nil {
+		for rows.Next() {
+			var key string
+			if rows.Scan(&key) == nil {
+				running[key] = true
+			}
+		}
+		rows.Close()
+	}
+
+	agentsDir := filepath.Join(config.GetOpenClawDir(), "agents")
+	dirs, err := os.ReadDir(agentsDir)
+	if err != nil {
+		return
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		agentID := agentIDForDir(d.Name())
+		sessionsDir := filepath.Join(agentsDir, d.Name(), "sessions")
+		aborted := abortedSessions(filepath.Join(sessionsDir, "sessions.json"))
+
+		files, err := os.ReadDir(sessionsDir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if f.IsDir() || !strings.HasSuffix(f.Name(), ".jsonl") {
+				continue
+			}
+			path := filepath.Join(sessionsDir, f.Name())
+			key := strings.TrimSuffix(f.Name(), ".jsonl")
+			if err := ingestSessionFile(path, key, agentID, offsets[path], aborted[key], running[key]); err != nil {
+				log.Printf("[ingest] %s: %v", path, err)
+			}
+		}
+	}
+
+	if err := rollupTaskMetrics(taskMetricsSince); err != nil {
+		log.Printf("[ingest] task metrics: %v", err)
+	}
+}
+
+// ingestSessionFile adds the usage appended to one session file since the
+// last pass to its agent_sessions row and the agent's daily metrics, and
+// advances the stored offset in the same transaction.
+func ingestSessionFile(path, key, agentID string, prev fileOffset, aborted, wasRunning bool) error {
+	lines, next, reset, err := readAppended(path, prev)
+	if err != nil {
+		return err
+	}
+
+	status := "completed"
+	var endedAt interface{} = next.ModTime
+	switch {
+	case aborted:
+		status = "failed"
+	case time.Since(next.ModTime) < sessionIdleAfter:
+		status, endedAt = "running", nil
+	}
+
+	if len(lines) == 0 && !reset && next.Offset == prev.Offset {
+		// Nothing new; just retire sessions that have gone quiet.
+		if wasRunning && status != "running" {
+			_, err := db.DB.Exec(`UPDATE agent_sessions SET status = $1, ended_at = $2 WHERE session_key = $3`,
+				status, endedAt, key)
+			return err
+		}
+		if !next.unchangedFrom(prev) {
+			return saveOffset(db.DB, sessionIngestConsumer, path, next)
+		}
+		return nil
+	}
+
+	type daily struct {
+		tokens int64
+		cost   float64
+	}
+	days := map[string]*daily{}
+	var tokensIn, tokensOut int64
+	var cost float64
+	startedAt := next.ModTime
+	for _, line := range lines {
+		msg, ok := parseTokenLine(line, agentID)
+		if !ok {
+			continue
+		}
+		tokensIn += msg.Input + msg.CacheRead + msg.CacheWrite
+		tokensOut += msg.Output
+		cost += msg.Cost()
+		if msg.Timestamp.Before(startedAt) {
+			startedAt = msg.Timestamp
+		}
+
+		day := msg.Timestamp.UTC().Format("2006-01-02")
+		d, ok := days[day]
+		if !ok {
+			d = &daily{}
+			days[day] = d
+		}
+		total := msg.TotalTokens
+		if total == 0 {
+			total = msg.Input + msg.CacheRead + msg.CacheWrite + msg.Output
+		}
+		d.tokens += total
+		d.cost += msg.Cost()
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if reset {
+		// The file was rewritten; recount the session from scratch, taking
+		// what it already added out of the daily metrics.
+		if _, err := tx.Exec(`UPDATE agent_sessions SET tokens_in = 0, tokens_out = 0, cost_estimate = 0 WHERE session_key = $1`, key); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			UPDATE agent_metrics m SET
+				tokens_used = m.tokens_used - u.tokens_used,
+				total_cost  = m.total_cost - u.total_cost
+			FROM agent_session_usage u
+			WHERE u.session_key = $1 AND m.agent_id = u.agent_id AND m.date = u.date`, key); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM agent_session_usage WHERE session_key = $1`, key); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO agent_sessions (session_key, agent_id, started_at, ended_at, tokens_in, tokens_out, cost_estimate, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (session_key) DO UPDATE SET
+			started_at    = LEAST(agent_sessions.started_at, EXCLUDED.started_at),
+			ended_at      = EXCLUDED.ended_at,
+			tokens_in     = agent_sessions.tokens_in + EXCLUDED.tokens_in,
+			tokens_out    = agent_sessions.tokens_out + EXCLUDED.tokens_out,
+			cost_estimate = agent_sessions.cost_estimate + EXCLUDED.cost_estimate,
+			status        = EXCLUDED.status`,
+		key, agentID, startedAt.UTC(), endedAt, tokensIn, tokensOut, cost, status); err != nil {
+		return err
+	}
+	for day, d := range days {
+		if _, err := tx.Exec(`
+			INSERT INTO agent_metrics (agent_id, date, tokens_used, total_cost)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (agent_id, date) DO UPDATE SET
+				tokens_used = agent_metrics.tokens_used + EXCLUDED.tokens_used,
+				total_cost  = agent_metrics.total_cost + EXCLUDED.total_cost`,
+			agentID, day, d.tokens, d.cost); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO agent_session_usage (session_key, date, agent_id, tokens_used, total_cost)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (session_key, date) DO UPDATE SET
+				tokens_used = agent_session_usage.tokens_used + EXCLUDED.tokens_used,
+				total_cost  = agent_session_usage.total_cost + EXCLUDED.total_cost`,
+			key, day, agentID, d.tokens, d.cost); err != nil {
+			return err
+		}
+	}
+	if err := saveOffset(tx, sessionIngestConsumer, path, next); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rollupTaskMetrics recomputes the task columns of agent_metrics from
+// task_history for every day since the given time. Completions are moves to
+// done; failures are moves to blocked and review bounces back to progress.
+// Each is credited to the task's assignee.
+func rollupTaskMetrics(since time.Time) error {
+	_, err := db.DB.Exec(`
+		INSERT INTO agent_metrics (agent_id, date, tasks_completed, tasks_failed, avg_completion_time_seconds)
+		SELECT t.assignee, h.changed_at::date,
+		       COUNT(*) FILTER (WHERE h.to_status IN `+doneStatusesSQL()+`),
+		       COUNT(*) FILTER (WHERE h.to_status = 'blocked'
+		                           OR (h.from_status = 'review' AND h.to_status = 'progress')),
+		       COALESCE(AVG(EXTRACT(EPOCH FROM h.changed_at - t.created_at))
+		                FILTER (WHERE h.to_status IN `+doneStatusesSQL()+`), 0)::int
+		FROM task_history h JOIN tasks t ON t.id = h.task_id
+		WHERE t.assignee IS NOT NULL AND t.assignee <> ''
+		  AND h.changed_at >= $1::date
+		GROUP BY t.assignee, h.changed_at::date
+		ON CONFLICT (agent_id, date) DO UPDATE SET
+			tasks_completed             = EXCLUDED.tasks_completed,
+			tasks_failed                = EXCLUDED.tasks_failed,
+			avg_completion_time_seconds = EXCLUDED.avg_completion_time_seconds`,
+		since.UTC().Format("2006-01-02"))
+	return err
+}
+
+// abortedSessions reads sessions.json and returns the session IDs whose last
+// run was aborted.
+func abortedSessions(path string) map[string]bool {
+	aborted := map[string]bool{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return aborted
+	}
+	var sessionsMap map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &sessionsMap); err != nil {
+		return aborted
+	}
+	for _, session := range sessionsMap {
+		id, _ := session["sessionId"].(string)
+		if a, _ := session["abortedLastRun"].(bool); a && id != "" {
+			aborted[id] = true
+		}
+	}
+	return aborted
+}
+
+// agentIDForDir maps a directory under {openclaw_dir}/agents to the
+// configured agent it belongs to, following legacy_dirs aliases.
+func agentIDForDir(dir string) string {
+	if ca := config.GetAgentByID(dir); ca != nil {
+		return ca.ID
+	}
+	if ca := config.GetAgent(dir); ca != nil {
+		return ca.ID
+	}
+	for name, aliases := range config.GetLegacyDirs() {
+		for _, alias := range aliases {
+			if alias != dir {
+				continue
+			}
+			if ca := config.GetAgent(name); ca != nil {
+				return ca.ID
+			}
+			return name
+		}
+	}
+	return dir
+}
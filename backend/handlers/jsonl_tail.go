@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/db"
+)
+
+// fileOffset is how far a consumer has read into an append-only JSONL file.
+type fileOffset struct {
+	Offset  int64
+	Size    int64
+	ModTime time.Time
+}
+
+func (o fileOffset) unchangedFrom(prev fileOffset) bool {
+	return o.Size == prev.Size && o.ModTime.Equal(prev.ModTime)
+}
+
+// readAppended returns the complete lines appended to path since prev and
+// the offset to resume from next time. A trailing line without a newline is
+// left for the next call, since the writer may still be mid-line. reset is
+// true when the file shrank (it was rewritten) and was read from the start.
+// Unchanged files return no lines without being opened.
+func readAppended(path string, prev fileOffset) (lines [][]byte, next fileOffset, reset bool, err error) {
+	appended, next, reset, err := readAppendedLines(path, prev)
+	for _, l := range appended {
+		lines = append(lines, l.Data)
+	}
+	return lines, next, reset, err
+}
+
+// appendedLine is a line returned by readAppendedLines and the byte offset
+// it starts at.
+type appendedLine struct {
+	Offset int64
+	Data   []byte
+}
+
+// readAppendedLines is readAppended for callers that need to know where
+// each line is in the file.
+func readAppendedLines(path string, prev fileOffset) (lines []appendedLine, next fileOffset, reset bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, prev, false, err
+	}
+	// Postgres keeps microseconds; truncate so a stored mtime compares equal.
+	next = fileOffset{Offset: prev.Offset, Size: info.Size(), ModTime: info.ModTime().Truncate(time.Microsecond)}
+	if next.unchangedFrom(prev) {
+		return nil, next, false, nil
+	}
+	if info.Size() < prev.Offset {
+		next.Offset = 0
+		reset = true
+	}
+	if info.Size() == next.Offset {
+		return nil, next, reset, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, prev, false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, info.Size()-next.Offset)
+	n, err := f.ReadAt(buf, next.Offset)
+	if err != nil && err != io.EOF {
+		return nil, prev, false, err
+	}
+	buf = buf[:n]
+
+	end := bytes.LastIndexByte(buf, '\n')
+	if end < 0 {
+		return nil, next, reset, nil
+	}
+	pos := next.Offset
+	for _, line := range bytes.Split(buf[:end], []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			lines = append(lines, appendedLine{Offset: pos, Data: line})
+		}
+		pos += int64(len(line)) + 1
+	}
+	next.Offset += int64(end + 1)
+	return lines, next, reset, nil
+}
+
+// loadOffsets returns every file offset stored for a consumer, keyed by path.
+func loadOffsets(consumer string) (map[string]fileOffset, error) {
+	rows, err := db.DB.Query(`SELECT path, "offset", size, mtime FROM jsonl_offsets WHERE consumer = $1`, consumer)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	offsets := map[string]fileOffset{}
+	for rows.Next() {
+		var path string
+		var o fileOffset
+		if err := rows.Scan(&path, &o.Offset, &o.Size, &o.ModTime); err != nil {
+			return nil, err
+		}
+		offsets[path] = o
+	}
+	return offsets, rows.Err()
+}
+
+// saveOffset persists a consumer's offset for path. Pass the transaction that
+// stored the data read so progress and data commit together.
+func saveOffset(ex execer, consumer, path string, o fileOffset) error {
+	_, err := ex.Exec(`
+		INSERT INTO jsonl_offsets (consumer, path, "offset", size, mtime, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (consumer, path) DO UPDATE
+		SET "offset" = EXCLUDED."offset", size = EXCLUDED.size, mtime = EXCLUDED.mtime, updated_at = NOW()`,
+		consumer, path, o.Offset, o.Size, o.ModTime)
+	return err
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/alghanim/agentboard/backend/auth"
+	"github.com/alghanim/agentboard/backend/config"
+	"github.com/alghanim/agentboard/backend/db"
+	"github.com/alghanim/agentboard/backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+type KeyHandler struct{}
+
+// CreateKey handles POST /api/keys (admin)
+// Body: {"kind": "agent", "agent_id": "arc"} or
+// {"kind": "user", "name": "alice", "role": "admin"|"member"}.
+// The token is returned once and never stored in the clear.
+func (h *KeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	if !allowAlways(w, r, "keys.manage", policyTarget{}) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+	var data struct {
+		Kind    string `json:"kind"`
+		AgentID string `json:"agent_id"`
+		Name    string `json:"name"`
+		Role    string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var agentID interface{}
+	switch data.Kind {
+	case auth.KindAgent:
+		ca := config.GetAgentByID(data.AgentID)
+		if ca == nil {
+			ca = config.GetAgent(data.AgentID)
+		}
+		if ca == nil {
+			respondError(w, http.StatusBadRequest, "agent_id must be a configured agent")
+			return
+		}
+		agentID = ca.ID
+		if data.Name == "" {
+			data.Name = ca.ID
+		}
+		// Agent permissions come from the hierarchy, not a role.
+		data.Role = auth.RoleMember
+	case auth.KindUser:
+		if data.Name == "" {
+			respondError(w, http.StatusBadRequest, "name is required for user keys")
+			return
+		}
+		if data.Role == "" {
+			data.Role = auth.RoleMember
+		}
+		if data.Role != auth.RoleMember && data.Role != auth.RoleAdmin {
+			respondError(w, http.StatusBadRequest, "role must be member or admin")
+			return
+		}
+	default:
+		respondError(w, http.StatusBadRequest, "kind must be agent or user")
+		return
+	}
+
+	token, hash, prefix, err := auth.NewToken(data.Kind)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	actor := getAgentFromContext(r)
+	key := models.APIKey{Kind: data.Kind, Name: data.Name, Role: data.Role, Prefix: prefix, CreatedBy: &actor}
+	if id, ok := agentID.(string); ok {
+		key.AgentID = &id
+	}
+	if err := db.DB.QueryRow(`
+		INSERT INTO api_keys (kind, agent_id, name, role, prefix, key_hash, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		data.Kind, agentID, data.Name, data.Role, prefix, hash, actor).Scan(&key.ID, &key.CreatedAt); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logActivity(actor, "api_key_created", "", map[string]string{"key_id": key.ID, "kind": key.Kind, "name": key.Name})
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"key":   key,
+		"token": token,
+	})
+}
+
+// ListKeys handles GET /api/keys (admin)
+// Pass include_revoked=true to also list revoked keys.
+func (h *KeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	if !allowAlways(w, r, "keys.manage", policyTarget{}) {
+		return
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT id, kind, agent_id, name, role, prefix, created_by, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE revoked_at IS NULL OR $1
+		ORDER BY created_at DESC`, r.URL.Query().Get("include_revoked") == "true")
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var k models.APIKey
+		var agentID, createdBy sql.NullString
+		var lastUsed, revoked sql.NullTime
+		if err := rows.Scan(&k.ID, &k.Kind, &agentID, &k.Name, &k.Role, &k.Prefix,
+			&createdBy, &k.CreatedAt, &lastUsed, &revoked); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		k.AgentID = models.NullStringToPtr(agentID)
+		k.CreatedBy = models.NullStringToPtr(createdBy)
+		k.LastUsedAt = models.NullTimeToPtr(lastUsed)
+		k.RevokedAt = models.NullTimeToPtr(revoked)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(w, http.StatusInternalServerError, "row iteration error: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, keys)
+}
+
+// RevokeKey handles DELETE /api/keys/{id} (admin)
+func (h *KeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	if !allowAlways(w, r, "keys.manage", policyTarget{}) {
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	result, err := db.DB.Exec(`UPDATE api_keys SET revoked_at = NOW() WHERE id::text = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondError(w, http.StatusNotFound, "Key not found or already revoked")
+		return
+	}
+	auth.Forget(id)
+
+	logActivity(getAgentFromContext(r), "api_key_revoked", "", map[string]string{"key_id": id})
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Key revoked"})
+}
+
+// GetMe handles GET /api/auth/me
+// Returns the identity behind the caller's token.
+func (h *KeyHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	if id, ok := auth.FromRequest(r); ok {
+		respondJSON(w, http.StatusOK, id)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"kind":         "anonymous",
+		"name":         getAgentFromContext(r),
+		"auth_enabled": auth.Enabled(),
+	})
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/db"
+
+	"github.com/lib/pq"
+)
+
+// LabelStats is one label's activity over the report window. Cycle times
+// are hours from creation to completion of the tasks completed in the
+// window; CycleTime is nil when none were.
+type LabelStats struct {
+	Label      string          `json:"label"`
+	Color      *string         `json:"color,omitempty"`
+	Registered bool            `json:"registered"`
+	Created    int             `json:"created"`
+	Completed  int             `json:"completed"`
+	Open       int             `json:"open"`
+	PerWeek    float64         `json:"throughput_per_week"`
+	CycleTime  *LabelCycleTime `json:"cycle_time_hours"`
+	Weekly     []LabelWeek     `json:"weekly"`
+}
+
+type LabelCycleTime struct {
+	Avg float64 `json:"avg"`
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+}
+
+// LabelWeek counts the tasks completed in the week starting on Week (a
+// Monday).
+type LabelWeek struct {
+	Week      string `json:"week"`
+	Completed int    `json:"completed"`
+}
+
+// GetLabelAnalytics handles GET /api/analytics/labels
+// Params: days (default 30, max 365), team, label (comma-separated). Per
+// label: tasks created and completed in the window, completions per week
+// (average and by week), cycle time, and tasks open now. Registered labels
+// in scope are listed even without activity. Most completed first.
+func (h *AnalyticsHandler) GetLabelAnalytics(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	days := 30
+	if v, err := strconv.Atoi(q.Get("days")); err == nil && v > 0 && v <= 365 {
+		days = v
+	}
+	team := q.Get("team")
+	only := splitList(q.Get("label"))
+
+	// Each row is a task and one of its labels.
+	var args sqlArgs
+	window := "NOW() - make_interval(days => " + args.add(days) + ")"
+	conds := []string{"TRUE"}
+	if team != "" {
+		conds = append(conds, "t.team = "+args.add(team))
+	}
+	if len(only) > 0 {
+		conds = append(conds, "u.label = ANY("+args.add(pq.Array(only))+"::text[])")
+	}
+	from := `FROM tasks t, unnest(t.labels) AS u(label) WHERE ` + strings.Join(conds, " AND ")
+
+	query := `
+		WITH tl AS (
+			SELECT DISTINCT t.id, u.label, t.status, t.created_at,
+			       t.status IN ` + doneStatusesSQL() + ` AND t.completed_at >= ` + window + ` AS done_in_window,
+			       EXTRACT(EPOCH FROM (t.completed_at - t.created_at)) / 3600 AS hours
+			` + from + `
+		)
+		SELECT label,
+			COUNT(*) FILTER (WHERE created_at >= ` + window + `),
+			COUNT(*) FILTER (WHERE done_in_window),
+			COUNT(*) FILTER (WHERE status NOT IN ` + doneStatusesSQL() + `),
+			ROUND(AVG(hours) FILTER (WHERE done_in_window)::numeric, 1),
+			ROUND((percentile_cont(0.5) WITHIN GROUP (ORDER BY hours) FILTER (WHERE done_in_window))::numeric, 1),
+			ROUND((percentile_cont(0.9) WITHIN GROUP (ORDER BY hours) FILTER (WHERE done_in_window))::numeric, 1)
+		FROM tl GROUP BY label`
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	byLabel := make(map[string]*LabelStats)
+	for rows.Next() {
+		s := &LabelStats{}
+		var avg, p50, p90 sql.NullFloat64
+		if err := rows.Scan(&s.Label, &s.Created, &s.Completed, &s.Open, &avg, &p50, &p90); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if avg.Valid {
+			s.CycleTime = &LabelCycleTime{Avg: avg.Float64, P50: p50.Float64, P90: p90.Float64}
+		}
+		byLabel[s.Label] = s
+	}
+	if err := rows.Err(); err != nil {
+		respondError(w, http.StatusInternalServerError, "row iteration error: "+err.Error())
+		return
+	}
+
+	// Registered labels: their colors, and a row for those without activity.
+	lrows, err := db.DB.Query(`SELECT name, color FROM labels
+		WHERE $1 = '' OR team IS NULL OR team = $1`, team)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer lrows.Close()
+	for lrows.Next() {
+		var name string
+		var color sql.NullString
+		if err := lrows.Scan(&name, &color); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(only) > 0 && !containsString(only, name) {
+			continue
+		}
+		s := byLabel[name]
+		if s == nil {
+			s = &LabelStats{Label: name}
+			byLabel[name] = s
+		}
+		s.Registered = true
+		if color.Valid {
+			s.Color = &color.String
+		}
+	}
+	if err := lrows.Err(); err != nil {
+		respondError(w, http.StatusInternalServerError, "row iteration error: "+err.Error())
+		return
+	}
+
+	// Completions per label and week, over every week the window touches.
+	var weeks []string
+	wrows, err := db.DB.Query(`
+		SELECT generate_series(date_trunc('week', NOW() - make_interval(days => $1)),
+		                       date_trunc('week', NOW()), '1 week')::date`, days)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer wrows.Close()
+	for wrows.Next() {
+		var week time.Time
+		if err := wrows.Scan(&week); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		weeks = append(weeks, week.Format("2006-01-02"))
+	}
+	if err := wrows.Err(); err != nil {
+		respondError(w, http.StatusInternalServerError, "row iteration error: "+err.Error())
+		return
+	}
+
+	query = `
+		SELECT u.label, date_trunc('week', t.completed_at)::date, COUNT(DISTINCT t.id)
+		` + from + ` AND t.status IN ` + doneStatusesSQL() + ` AND t.completed_at >= ` + window + `
+		GROUP BY 1, 2`
+	crows, err := db.DB.Query(query, args...)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer crows.Close()
+	completed := make(map[string]map[string]int)
+	for crows.Next() {
+		var label string
+		var week time.Time
+		var n int
+		if err := crows.Scan(&label, &week, &n); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if completed[label] == nil {
+			completed[label] = make(map[string]int)
+		}
+		completed[label][week.Format("2006-01-02")] = n
+	}
+	if err := crows.Err(); err != nil {
+		respondError(w, http.StatusInternalServerError, "row iteration error: "+err.Error())
+		return
+	}
+
+	results := make([]LabelStats, 0, len(byLabel))
+	for _, s := range byLabel {
+		s.PerWeek = math.Round(float64(s.Completed)/(float64(days)/7)*100) / 100
+		s.Weekly = make([]LabelWeek, len(weeks))
+		for i, week := range weeks {
+			s.Weekly[i] = LabelWeek{Week: week, Completed: completed[s.Label][week]}
+		}
+		results = append(results, *s)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Completed != results[j].Completed {
+			return results[i].Completed > results[j].Completed
+		}
+		return results[i].Label < results[j].Label
+	})
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"days":   days,
+		"labels": results,
+	})
+}
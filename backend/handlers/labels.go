@@ -0,0 +1,550 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alghanim/agentboard/backend/db"
+	"github.com/alghanim/agentboard/backend/models"
+	"github.com/alghanim/agentboard/backend/websocket"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+type LabelHandler struct {
+	Hub *websocket.Hub
+}
+
+// labelColumns reads a labels row aliased l, with the number of tasks that
+// carry the label as spelled.
+const labelColumns = `l.id, l.name, l.color, l.description, l.team,
+	(SELECT COUNT(*) FROM tasks t WHERE t.labels @> ARRAY[l.name::text]),
+	l.created_by, l.created_at, l.updated_at`
+
+var labelColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// ListLabels handles GET /api/labels
+// With team, only global labels and that team's. include_unregistered=true
+// appends the labels used on tasks that aren't in the catalog (no id), which
+// are the candidates for a merge.
+func (h *LabelHandler) ListLabels(w http.ResponseWriter, r *http.Request) {
+	team := r.URL.Query().Get("team")
+	labels, err := queryLabels(`SELECT `+labelColumns+` FROM labels l
+		WHERE $1 = '' OR l.team IS NULL OR l.team = $1 ORDER BY LOWER(l.name)`, team)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if r.URL.Query().Get("include_unregistered") == "true" {
+		rows, err := db.DB.Query(`
+			SELECT u.label, COUNT(DISTINCT t.id)
+			FROM tasks t, unnest(t.labels) AS u(label)
+			WHERE ($1 = '' OR t.team = $1)
+			  AND NOT EXISTS (SELECT 1 FROM labels l WHERE l.name = u.label)
+			GROUP BY u.label
+			ORDER BY LOWER(u.label), u.label`, team)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var l models.Label
+			if err := rows.Scan(&l.Name, &l.TaskCount); err != nil {
+				respondError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			labels = append(labels, l)
+		}
+		if err := rows.Err(); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	respondJSON(w, http.StatusOK, labels)
+}
+
+// GetLabel handles GET /api/labels/{id}
+// The label may be named by ID or, case-insensitively, by name.
+func (h *LabelHandler) GetLabel(w http.ResponseWriter, r *http.Request) {
+	l, ok := loadLabel(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, l)
+}
+
+// CreateLabel handles POST /api/labels
+// Body: {"name": "frontend", "color": "#3b82f6", "description": "...",
+// "team": "Engineering"}. Names are unique regardless of case; a label
+// without a team is global.
+func (h *LabelHandler) CreateLabel(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "labels.manage", policyTarget{}) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+	var data struct {
+		Name        string  `json:"name"`
+		Color       *string `json:"color"`
+		Description *string `json:"description"`
+		Team        *string `json:"team"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	name, err := validateLabel(data.Name, data.Color)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	actor := getAgentFromContext(r)
+	var id string
+	err = db.DB.QueryRow(`
+		INSERT INTO labels (name, color, description, team, created_by)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), $5)
+		RETURNING id`,
+		name, models.PtrToNullString(data.Color), models.PtrToNullString(data.Description),
+		models.PtrToNullString(data.Team), actor).Scan(&id)
+	if isUniqueViolation(err) {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Label %q already exists", name))
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	l, ok := loadLabel(w, id)
+	if !ok {
+		return
+	}
+
+	logActivity(actor, "label_created", "", map[string]string{"label_id": l.ID, "name": l.Name})
+	h.Hub.Broadcast("label_created", l, labelTopics(l)...)
+	respondJSON(w, http.StatusCreated, l)
+}
+
+// UpdateLabel handles PUT /api/labels/{id}
+// Accepts the CreateLabel fields; omitted fields are kept and "" clears
+// color, description or team. A new name renames the label on every task
+// and saved view that carries it, in any case.
+func (h *LabelHandler) UpdateLabel(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "labels.manage", policyTarget{}) {
+		return
+	}
+	current, ok := loadLabel(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+	var data struct {
+		Name        *string `json:"name"`
+		Color       *string `json:"color"`
+		Description *string `json:"description"`
+		Team        *string `json:"team"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	oldName := current.Name
+	if data.Name != nil {
+		current.Name = *data.Name
+	}
+	if data.Color != nil {
+		current.Color = data.Color
+	}
+	if data.Description != nil {
+		current.Description = data.Description
+	}
+	if data.Team != nil {
+		current.Team = data.Team
+	}
+	name, err := validateLabel(current.Name, current.Color)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE labels SET name = $1, color = NULLIF($2, ''), description = NULLIF($3, ''),
+		       team = NULLIF($4, ''), updated_at = NOW()
+		WHERE id = $5`,
+		name, models.PtrToNullString(current.Color), models.PtrToNullString(current.Description),
+		models.PtrToNullString(current.Team), current.ID)
+	if isUniqueViolation(err) {
+		respondError(w, http.StatusConflict, fmt.Sprintf("Label %q already exists; merge into it instead", name))
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	actor := getAgentFromContext(r)
+	var rel relabeled
+	if name != oldName {
+		if rel, err = relabel(tx, []string{oldName}, name, actor); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.broadcastRelabeled(rel, actor)
+
+	l, ok := loadLabel(w, current.ID)
+	if !ok {
+		return
+	}
+	details := map[string]string{"label_id": l.ID, "name": l.Name}
+	if name != oldName {
+		details["old_name"] = oldName
+	}
+	logActivity(actor, "label_updated", "", details)
+	h.Hub.Broadcast("label_updated", l, labelTopics(l)...)
+	respondJSON(w, http.StatusOK, l)
+}
+
+// DeleteLabel handles DELETE /api/labels/{id}
+// Only the catalog entry goes; tasks keep the label, which becomes
+// unregistered. Merge it into another label to take it off tasks.
+func (h *LabelHandler) DeleteLabel(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "labels.manage", policyTarget{}) {
+		return
+	}
+	l, ok := loadLabel(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	if _, err := db.DB.Exec(`DELETE FROM labels WHERE id = $1`, l.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logActivity(getAgentFromContext(r), "label_deleted", "", map[string]string{"label_id": l.ID, "name": l.Name})
+	h.Hub.Broadcast("label_deleted", map[string]string{"id": l.ID, "name": l.Name}, labelTopics(l)...)
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Label deleted"})
+}
+
+// MergeLabels handles POST /api/labels/merge
+// Body: {"sources": ["front-end", "FE"], "into": "frontend"}. Every task and
+// saved view label matching a source, or another spelling of the target,
+// becomes the target (a registered label, by ID or name). Sources may be
+// unregistered; registered ones are removed from the catalog.
+func (h *LabelHandler) MergeLabels(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, "labels.manage", policyTarget{}) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+	var data struct {
+		Sources []string `json:"sources"`
+		Into    string   `json:"into"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var sources []string
+	for _, s := range data.Sources {
+		if s = strings.TrimSpace(s); s != "" {
+			sources = append(sources, s)
+		}
+	}
+	if len(sources) == 0 || strings.TrimSpace(data.Into) == "" {
+		respondError(w, http.StatusBadRequest, "sources and into are required")
+		return
+	}
+	into, ok := loadLabel(w, strings.TrimSpace(data.Into))
+	if !ok {
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	actor := getAgentFromContext(r)
+	rel, err := relabel(tx, append(sources, into.Name), into.Name, actor)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	lowered := make([]string, len(sources))
+	for i, s := range sources {
+		lowered[i] = strings.ToLower(s)
+	}
+	var removed pq.StringArray
+	if err := tx.QueryRow(`
+		WITH d AS (DELETE FROM labels WHERE LOWER(name) = ANY($1) AND id <> $2 RETURNING name)
+		SELECT COALESCE(array_agg(name ORDER BY name), '{}') FROM d`,
+		pq.Array(lowered), into.ID).Scan(&removed); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.broadcastRelabeled(rel, actor)
+
+	into, ok = loadLabel(w, into.ID)
+	if !ok {
+		return
+	}
+	result := map[string]interface{}{
+		"label":         into,
+		"sources":       sources,
+		"removed":       []string(removed),
+		"tasks_updated": len(rel.Tasks),
+		"views_updated": rel.Views,
+	}
+	logActivity(actor, "labels_merged", "", map[string]string{
+		"label_id": into.ID, "into": into.Name, "sources": strings.Join(sources, ","),
+	})
+	h.Hub.Broadcast("labels_merged", result, labelTopics(into)...)
+	respondJSON(w, http.StatusOK, result)
+}
+
+// relabeled is what a relabel changed: the IDs of the tasks it rewrote and
+// the number of saved views.
+type relabeled struct {
+	Tasks []string
+	Views int
+}
+
+// relabel replaces every label matching one of from, case-insensitively,
+// with to, on tasks and in saved view filters. Tasks keep their label order
+// and a label that ends up twice is kept once. Task changes are recorded in
+// the field history; broadcast them with broadcastRelabeled once tx commits.
+func relabel(tx *sql.Tx, from []string, to, actor string) (relabeled, error) {
+	var rel relabeled
+	match := make(map[string]bool)
+	var lowered []string
+	for _, f := range from {
+		if l := strings.ToLower(f); !match[l] {
+			match[l] = true
+			lowered = append(lowered, l)
+		}
+	}
+
+	type taskLabels struct {
+		id     string
+		labels pq.StringArray
+	}
+	var tasks []taskLabels
+	rows, err := tx.Query(`
+		SELECT id, labels FROM tasks
+		WHERE EXISTS (SELECT 1 FROM unnest(labels) l WHERE LOWER(l) = ANY($1))
+		FOR UPDATE`, pq.Array(lowered))
+	if err != nil {
+		return rel, err
+	}
+	for rows.Next() {
+		var t taskLabels
+		if err := rows.Scan(&t.id, &t.labels); err != nil {
+			rows.Close()
+			return rel, err
+		}
+		tasks = append(tasks, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return rel, err
+	}
+
+	for _, t := range tasks {
+		labels, changed := replaceLabels(t.labels, match, to)
+		if !changed {
+			continue
+		}
+		if _, err := tx.Exec(`UPDATE tasks SET labels = $1 WHERE id = $2`, pq.Array(labels), t.id); err != nil {
+			return rel, err
+		}
+		o, _ := json.Marshal([]string(t.labels))
+		n, _ := json.Marshal(labels)
+		if err := recordFieldChanges(tx, t.id, []fieldChange{{Field: "labels", OldValue: o, NewValue: n}}, actor); err != nil {
+			return rel, err
+		}
+		rel.Tasks = append(rel.Tasks, t.id)
+	}
+
+	views, err := tx.Query(`SELECT id, filters FROM saved_views FOR UPDATE`)
+	if err != nil {
+		return rel, err
+	}
+	updates := make(map[string][]byte)
+	for views.Next() {
+		var id string
+		var raw []byte
+		if err := views.Scan(&id, &raw); err != nil {
+			views.Close()
+			return rel, err
+		}
+		f, err := decodeTaskFilter(raw)
+		if err != nil {
+			continue // not ours to fix here
+		}
+		var anyChanged, allChanged bool
+		f.Labels, anyChanged = replaceLabels(f.Labels, match, to)
+		f.LabelsAll, allChanged = replaceLabels(f.LabelsAll, match, to)
+		if anyChanged || allChanged {
+			updates[id], _ = json.Marshal(f)
+		}
+	}
+	views.Close()
+	if err := views.Err(); err != nil {
+		return rel, err
+	}
+	for id, filters := range updates {
+		if _, err := tx.Exec(`UPDATE saved_views SET filters = $1, updated_at = NOW() WHERE id = $2`, filters, id); err != nil {
+			return rel, err
+		}
+	}
+	rel.Views = len(updates)
+	return rel, nil
+}
+
+// replaceLabels returns labels with every one in match (lower-cased) replaced
+// by to, without duplicates, and whether that changed anything.
+func replaceLabels(labels []string, match map[string]bool, to string) ([]string, bool) {
+	if len(labels) == 0 {
+		return labels, false
+	}
+	out := make([]string, 0, len(labels))
+	seen := make(map[string]bool)
+	changed := false
+	for _, l := range labels {
+		if match[strings.ToLower(l)] && l != to {
+			l, changed = to, true
+		}
+		if seen[l] {
+			changed = true
+			continue
+		}
+		seen[l] = true
+		out = append(out, l)
+	}
+	return out, changed
+}
+
+// broadcastRelabeled sends task_updated for each task a relabel changed.
+func (h *LabelHandler) broadcastRelabeled(rel relabeled, actor string) {
+	if rel.Views > 0 {
+		savedViews.invalidate()
+	}
+	for _, id := range rel.Tasks {
+		t, err := loadTask(id)
+		if err != nil {
+			continue
+		}
+		logActivity(actor, "task_updated", id, map[string]string{"fields": "labels"})
+		h.Hub.Broadcast("task_updated", t, taskEventTopics(t)...)
+	}
+}
+
+// validateLabel checks a label's name and color and returns the name
+// trimmed. Commas are refused because label filters take comma lists.
+func validateLabel(name string, color *string) (string, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return "", errors.New("name is required")
+	case utf8.RuneCountInString(name) > 100:
+		return "", errors.New("name must be at most 100 characters")
+	case strings.Contains(name, ","):
+		return "", errors.New("name must not contain a comma")
+	}
+	if color != nil && *color != "" && !labelColorPattern.MatchString(*color) {
+		return "", fmt.Errorf("color %q is not a hex color such as #3b82f6", *color)
+	}
+	return name, nil
+}
+
+// labelTopics returns the broadcast topics for a label event: its team's,
+// if it has one.
+func labelTopics(l models.Label) []string {
+	if l.Team != nil {
+		return []string{websocket.Topic("team", *l.Team)}
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// loadLabel loads a registered label by ID or name, answering 404 if there
+// is none.
+func loadLabel(w http.ResponseWriter, idOrName string) (models.Label, bool) {
+	l, err := scanLabel(db.DB.QueryRow(`SELECT `+labelColumns+` FROM labels l
+		WHERE l.id::text = $1 OR LOWER(l.name) = LOWER($1)`, idOrName))
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Label not found")
+		return l, false
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return l, false
+	}
+	return l, true
+}
+
+func scanLabel(row rowScanner) (models.Label, error) {
+	var l models.Label
+	var color, desc, team sql.NullString
+	var createdAt, updatedAt sql.NullTime
+	if err := row.Scan(&l.ID, &l.Name, &color, &desc, &team, &l.TaskCount, &l.CreatedBy,
+		&createdAt, &updatedAt); err != nil {
+		return l, err
+	}
+	l.Color = models.NullStringToPtr(color)
+	l.Description = models.NullStringToPtr(desc)
+	l.Team = models.NullStringToPtr(team)
+	l.CreatedAt = models.NullTimeToPtr(createdAt)
+	l.UpdatedAt = models.NullTimeToPtr(updatedAt)
+	return l, nil
+}
+
+func queryLabels(query string, args ...interface{}) ([]models.Label, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := []models.Label{}
+	for rows.Next() {
+		l, err := scanLabel(rows)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+	return labels, rows.Err()
+}
@@ -43,30 +43,6 @@ func agentFromConfig(a config.Agent) OCAgent {
 
 type OpenClawHandler struct{}
 
-// StartAgentStatusPoller runs in a goroutine and broadcasts agent status changes.
-func StartAgentStatusPoller(hub interface{ Broadcast(string, interface{}) }) {
-	prevStatuses := make(map[string]string)
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		cfgAgents := config.GetAgents()
-		agents := make([]OCAgentStatus, 0, len(cfgAgents))
-		changed := false
-		for _, ca := range cfgAgents {
-			s := getOCAgentStatus(agentFromConfig(ca))
-			agents = append(agents, s)
-			if prev, ok := prevStatuses[ca.Name]; !ok || prev != s.Status {
-				changed = true
-				prevStatuses[ca.Name] = s.Status
-			}
-		}
-		if changed {
-			hub.Broadcast("agent_status_update", agents)
-		}
-	}
-}
-
 // --- Response types ---
 
 type OCAgentStatus struct {
@@ -103,17 +79,19 @@ type OCTranscriptEntry struct {
 }
 
 type OCStreamEntry struct {
-	Timestamp time.Time `json:"timestamp"`
-	TimeStr   string    `json:"timeStr"`
-	TimeAbs   string    `json:"timeAbs"`
-	Agent     string    `json:"agent"`
-	Emoji     string    `json:"emoji"`
-	TeamColor string    `json:"teamColor"`
-	Type      string    `json:"type"`
-	Content   string    `json:"content"`
-	ToolName  string    `json:"toolName,omitempty"`
-	ExitCode  *int      `json:"exitCode,omitempty"`
-	IsError   bool      `json:"isError,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	TimeStr   string                 `json:"timeStr"`
+	TimeAbs   string                 `json:"timeAbs"`
+	Agent     string                 `json:"agent"`
+	AgentID   string                 `json:"agentId"`
+	Emoji     string                 `json:"emoji"`
+	TeamColor string                 `json:"teamColor"`
+	Type      string                 `json:"type"`
+	Content   string                 `json:"content"`
+	ToolName  string                 `json:"toolName,omitempty"`
+	ToolInput map[string]interface{} `json:"toolInput,omitempty"`
+	ExitCode  *int                   `json:"exitCode,omitempty"`
+	IsError   bool                   `json:"isError,omitempty"`
 }
 
 type OCStats struct {
@@ -204,6 +182,10 @@ func (h *OpenClawHandler) GetStream(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	agentFilter := r.URL.Query().Get("agent_id")
+	if entries, ok := transcripts.latest(limit, agentFilter); ok {
+		writeJSON(w, entries)
+		return
+	}
 	writeJSON(w, getOCStream(limit, agentFilter))
 }
 
@@ -322,80 +304,59 @@ func (h *OpenClawHandler) GetAgentSkills(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	writeJSON(w, discoverSkills(*ca))
+}
+
+// discoverSkills lists the global skills plus the agent's workspace skills.
+func discoverSkills(ca config.Agent) []SkillInfo {
 	openClawDir := config.GetOpenClawDir()
-	var skills []SkillInfo
+	skills := []SkillInfo{}
 
 	// Read from global skills directory
-	globalSkillsDir := filepath.Join(openClawDir, "skills")
-	entries, err := os.ReadDir(globalSkillsDir)
-	if err == nil {
-		for _, e := range entries {
-			if !e.IsDir() {
-				continue
-			}
-			skillMDPath := filepath.Join(globalSkillsDir, e.Name(), "SKILL.md")
-			data, readErr := os.ReadFile(skillMDPath)
-			description := ""
-			if readErr == nil {
-				lines := strings.Split(string(data), "\n")
-				// Use first non-empty, non-heading line as description
-				for _, line := range lines {
-					trimmed := strings.TrimSpace(line)
-					if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-						continue
-					}
-					description = trimmed
-					break
-				}
-				// Fall back to the heading title
-				if description == "" && len(lines) > 0 {
-					description = strings.TrimPrefix(strings.TrimSpace(lines[0]), "# ")
-				}
-			}
-			skills = append(skills, SkillInfo{
-				Name:        e.Name(),
-				Description: description,
-			})
-		}
-	}
+	skills = append(skills, readSkillsDir(filepath.Join(openClawDir, "skills"))...)
 
 	// Also check agent-specific workspace skills dir
-	agentID := ca.ID
-	workspaceSkillsDir := filepath.Join(openClawDir, "workspace-"+agentID, "skills")
-	agentEntries, err := os.ReadDir(workspaceSkillsDir)
-	if err == nil {
-		for _, e := range agentEntries {
-			if !e.IsDir() {
-				continue
-			}
-			skillMDPath := filepath.Join(workspaceSkillsDir, e.Name(), "SKILL.md")
-			data, readErr := os.ReadFile(skillMDPath)
-			description := ""
-			if readErr == nil {
-				lines := strings.Split(string(data), "\n")
-				for _, line := range lines {
-					trimmed := strings.TrimSpace(line)
-					if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-						continue
-					}
-					description = trimmed
-					break
-				}
-				if description == "" && len(lines) > 0 {
-					description = strings.TrimPrefix(strings.TrimSpace(lines[0]), "# ")
+	skills = append(skills, readSkillsDir(filepath.Join(openClawDir, "workspace-"+ca.ID, "skills"))...)
+
+	return skills
+}
+
+// readSkillsDir reads every {dir}/{skill}/SKILL.md into a SkillInfo.
+func readSkillsDir(dir string) []SkillInfo {
+	var skills []SkillInfo
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		skillMDPath := filepath.Join(dir, e.Name(), "SKILL.md")
+		data, readErr := os.ReadFile(skillMDPath)
+		description := ""
+		if readErr == nil {
+			lines := strings.Split(string(data), "\n")
+			// Use first non-empty, non-heading line as description
+			for _, line := range lines {
+				trimmed := strings.TrimSpace(line)
+				if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+					continue
 				}
+				description = trimmed
+				break
+			}
+			// Fall back to the heading title
+			if description == "" && len(lines) > 0 {
+				description = strings.TrimPrefix(strings.TrimSpace(lines[0]), "# ")
 			}
-			skills = append(skills, SkillInfo{
-				Name:        e.Name(),
-				Description: description,
-			})
 		}
+		skills = append(skills, SkillInfo{
+			Name:        e.Name(),
+			Description: description,
+		})
 	}
-
-	if skills == nil {
-		skills = []SkillInfo{}
-	}
-	writeJSON(w, skills)
+	return skills
 }
 
 // --- Core logic ---
@@ -474,20 +435,12 @@ func getOCAgentStatus(agent OCAgent) OCAgentStatus {
 	if !latestSession.IsZero() {
 		status.LastActive = latestSession
 		status.LastActiveStr = formatRelTime(latestSession)
-		since := time.Since(latestSession)
-		switch {
-		case since < 5*time.Minute:
-			status.Status = "active"
-		case since < 30*time.Minute:
-			status.Status = "idle"
-		default:
-			status.Status = "offline"
-		}
+		status.Status = statusSince(time.Since(latestSession), config.GetStatusThresholds())
 	} else {
 		status.LastActiveStr = "Never"
 	}
 
-	status.EstimatedCost = calcCost(status.CurrentModel, totalInput, totalOutput)
+	status.EstimatedCost = sessionDirsCost(getSessionDirs(agent))
 	status.CurrentTask = getLatestTask(agent.Name)
 
 	return status
@@ -639,6 +592,18 @@ func getOCStats() OCStats {
 
 // --- Helpers ---
 
+// statusSince classifies an agent by the time since its last activity.
+func statusSince(since time.Duration, th config.StatusThresholds) string {
+	switch {
+	case since < th.ActiveWithin:
+		return "active"
+	case since < th.IdleWithin:
+		return "idle"
+	default:
+		return "offline"
+	}
+}
+
 func getLatestTask(agentName string) string {
 	ca := config.GetAgent(agentName)
 	if ca == nil {
@@ -746,7 +711,19 @@ func readLastJSONLEntries(path string, maxBytes int) []map[string]interface{} {
 }
 
 func parseJSONLToStream(entry map[string]interface{}, agent OCAgent) []OCStreamEntry {
+	return parseStreamEntries(entry, agent, false)
+}
+
+// parseStreamEntries turns one transcript line into stream entries. With
+// full set, content is not truncated and tool calls carry their arguments.
+func parseStreamEntries(entry map[string]interface{}, agent OCAgent, full bool) []OCStreamEntry {
 	var results []OCStreamEntry
+	clip := func(s string) string {
+		if full {
+			return s
+		}
+		return truncate(s, 500)
+	}
 
 	entryType, _ := entry["type"].(string)
 	if entryType != "message" {
@@ -764,6 +741,7 @@ func parseJSONLToStream(entry map[string]interface{}, agent OCAgent) []OCStreamE
 		TimeStr:   formatRelTime(ts),
 		TimeAbs:   ts.Local().Format("15:04:05"),
 		Agent:     agent.Name,
+		AgentID:   agent.ID,
 		Emoji:     agent.Emoji,
 		TeamColor: agent.TeamColor,
 	}
@@ -776,7 +754,7 @@ func parseJSONLToStream(entry map[string]interface{}, agent OCAgent) []OCStreamE
 		if text != "" {
 			e := base
 			e.Type = "prompt"
-			e.Content = truncate(text, 500)
+			e.Content = clip(text)
 			results = append(results, e)
 		}
 
@@ -797,7 +775,7 @@ func parseJSONLToStream(entry map[string]interface{}, agent OCAgent) []OCStreamE
 				if strings.TrimSpace(text) != "" {
 					e := base
 					e.Type = "response"
-					e.Content = truncate(text, 500)
+					e.Content = clip(text)
 					results = append(results, e)
 				}
 			case "toolCall", "tool_use":
@@ -807,6 +785,9 @@ func parseJSONLToStream(entry map[string]interface{}, agent OCAgent) []OCStreamE
 				e.Type = "command"
 				e.ToolName = toolName
 				e.Content = formatCommand(toolName, args)
+				if full {
+					e.ToolInput = args
+				}
 				results = append(results, e)
 			}
 		}
@@ -817,7 +798,7 @@ func parseJSONLToStream(entry map[string]interface{}, agent OCAgent) []OCStreamE
 		e := base
 		e.Type = "result"
 		e.ToolName = toolName
-		e.Content = truncate(content, 500)
+		e.Content = clip(content)
 		if details, ok := msg["details"].(map[string]interface{}); ok {
 			if ec, ok := details["exitCode"].(float64); ok {
 				code := int(ec)
@@ -965,12 +946,26 @@ func formatCommand(toolName string, args map[string]interface{}) string {
 }
 
 func parseTS(entry map[string]interface{}) time.Time {
-	if ts, ok := entry["timestamp"].(float64); ok {
-		return time.Unix(0, int64(ts)*int64(time.Millisecond))
+	if t, ok := entryTime(entry); ok {
+		return t
 	}
 	return time.Now()
 }
 
+// entryTime reads a transcript line's timestamp, in epoch milliseconds or
+// RFC 3339.
+func entryTime(entry map[string]interface{}) (time.Time, bool) {
+	if ts, ok := entry["timestamp"].(float64); ok {
+		return time.Unix(0, int64(ts)*int64(time.Millisecond)), true
+	}
+	if ts, ok := entry["timestamp"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
 func formatRelTime(t time.Time) string {
 	diff := time.Since(t)
 	switch {
@@ -1008,19 +1003,17 @@ func fmtDuration(d time.Duration) string {
 	}
 }
 
-func calcCost(model string, input, output int64) float64 {
-	var ic, oc float64
-	switch {
-	case strings.Contains(model, "opus"):
-		ic, oc = 15.0, 75.0
-	case strings.Contains(model, "sonnet"):
-		ic, oc = 3.0, 15.0
-	case strings.Contains(model, "haiku"):
-		ic, oc = 0.25, 1.25
-	default:
-		ic, oc = 3.0, 15.0
+// sessionDirsCost sums the cost of every indexed message from the given
+// session directories, each at its reported cost or the rates in force
+// when it was sent, cache tokens included.
+func sessionDirsCost(dirs []string) float64 {
+	var cost float64
+	for _, m := range tokenMessages() {
+		if containsString(dirs, m.AgentID) {
+			cost += m.Cost()
+		}
 	}
-	return (float64(input)/1e6)*ic + (float64(output)/1e6)*oc
+	return cost
 }
 
 func truncate(s string, max int) string {
@@ -26,12 +26,12 @@ func (h *PerformanceHandler) GetPerformance(w http.ResponseWriter, r *http.Reque
 		SELECT
 			a.id as agent_id,
 			COALESCE(a.display_name, a.id) as name,
-			COUNT(CASE WHEN t.status='done' AND t.updated_at >= NOW()-INTERVAL '1 day' THEN 1 END) as today,
-			COUNT(CASE WHEN t.status='done' AND t.updated_at >= NOW()-INTERVAL '7 days' THEN 1 END) as week,
-			COUNT(CASE WHEN t.status='progress' THEN 1 END) as in_progress,
+			COUNT(CASE WHEN t.status IN ` + doneStatusesSQL() + ` AND t.updated_at >= NOW()-INTERVAL '1 day' THEN 1 END) as today,
+			COUNT(CASE WHEN t.status IN ` + doneStatusesSQL() + ` AND t.updated_at >= NOW()-INTERVAL '7 days' THEN 1 END) as week,
+			COUNT(CASE WHEN t.status IN ` + workingStatusesSQL() + ` THEN 1 END) as in_progress,
 			COUNT(t.id) as total,
 			COALESCE(
-				ROUND(AVG(CASE WHEN t.status='done'
+				ROUND(AVG(CASE WHEN t.status IN ` + doneStatusesSQL() + `
 					THEN EXTRACT(EPOCH FROM (t.updated_at - t.created_at))/3600
 					END)::numeric, 1),
 				0
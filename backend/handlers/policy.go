@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alghanim/agentboard/backend/auth"
+	"github.com/alghanim/agentboard/backend/config"
+	"github.com/alghanim/agentboard/backend/db"
+	"github.com/alghanim/agentboard/backend/models"
+)
+
+// policyTarget is what a policy check is judged against.
+type policyTarget struct {
+	TaskID      string
+	Assignee    string // current assignee, "" if unassigned
+	Team        string // task team, falling back to the assignee's
+	NewAssignee string // task.assign only
+}
+
+// subjectLabels describe policy subjects in denial reasons.
+var subjectLabels = map[string]string{
+	config.SubjectSelf:     "its assignee",
+	config.SubjectSubtree:  "agents above its assignee",
+	config.SubjectTeamLead: "leads of its team",
+	config.SubjectAdmin:    "admins",
+	config.SubjectUser:     "human users",
+	config.SubjectAnyone:   "anyone",
+}
+
+// authorize reports why the caller may not perform action on target, or ""
+// if it may. Policies only apply with auth enabled; without it there is no
+// trustworthy identity to judge.
+func authorize(r *http.Request, action string, t policyTarget) string {
+	if !auth.Enabled() {
+		return ""
+	}
+	return checkPolicy(r, action, t)
+}
+
+// checkPolicy is authorize whether or not auth is enabled. Without auth,
+// only a caller that still presents a valid token can pass.
+func checkPolicy(r *http.Request, action string, t policyTarget) string {
+	id, ok := auth.FromRequest(r)
+	if !ok {
+		return "Authentication required"
+	}
+
+	subjects := config.GetPolicies().Rules[action]
+	for _, s := range subjects {
+		if subjectMatches(id, s, t) {
+			return ""
+		}
+	}
+
+	var who []string
+	for _, s := range subjects {
+		who = append(who, subjectLabels[s])
+	}
+	allowed := "nobody"
+	if len(who) > 0 {
+		allowed = strings.Join(who, ", ")
+	}
+	what := action
+	if t.TaskID != "" {
+		what += " on task " + t.TaskID
+	}
+	reason := fmt.Sprintf("%s may not %s: allowed for %s", id.Actor(), what, allowed)
+	if t.NewAssignee != "" && containsString(subjects, config.SubjectTeamLead) {
+		reason += fmt.Sprintf(" (leads only within their team; new assignee %s)", t.NewAssignee)
+	}
+	return reason
+}
+
+// allow writes a 403 with the reason and returns false if the caller may
+// not perform action on target.
+func allow(w http.ResponseWriter, r *http.Request, action string, t policyTarget) bool {
+	if reason := authorize(r, action, t); reason != "" {
+		respondError(w, http.StatusForbidden, "Forbidden: "+reason)
+		return false
+	}
+	return true
+}
+
+// allowAlways is allow for actions that stay closed while auth is disabled,
+// such as minting keys or registering webhooks that would remain in effect
+// once it is enabled.
+func allowAlways(w http.ResponseWriter, r *http.Request, action string, t policyTarget) bool {
+	if reason := checkPolicy(r, action, t); reason != "" {
+		respondError(w, http.StatusForbidden, "Forbidden: "+reason)
+		return false
+	}
+	return true
+}
+
+// allowOnTask is allow for an existing task, loading its assignee and team.
+// It writes a 404 for unknown tasks.
+func allowOnTask(w http.ResponseWriter, r *http.Request, action, taskID string) bool {
+	if !auth.Enabled() {
+		return true
+	}
+	t, err := taskPolicyTarget(taskID)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Task not found")
+		return false
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return allow(w, r, action, t)
+}
+
+// allowAssign is allowOnTask for task.assign, judged with the new assignee.
+func allowAssign(w http.ResponseWriter, r *http.Request, taskID, newAssignee string) bool {
+	if !auth.Enabled() {
+		return true
+	}
+	t, err := taskPolicyTarget(taskID)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Task not found")
+		return false
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	t.NewAssignee = newAssignee
+	return allow(w, r, "task.assign", t)
+}
+
+// allowTaskChanges checks a PUT or PATCH: task.update always, plus
+// task.transition and task.assign when the status or assignee changes.
+func allowTaskChanges(w http.ResponseWriter, r *http.Request, before, after models.Task) bool {
+	if !auth.Enabled() {
+		return true
+	}
+	oldAssignee := models.PtrToNullString(before.Assignee).String
+	newAssignee := models.PtrToNullString(after.Assignee).String
+	t := newPolicyTarget(before.ID, oldAssignee, models.PtrToNullString(before.Team).String)
+	if !allow(w, r, "task.update", t) {
+		return false
+	}
+	if after.Status != before.Status && !allow(w, r, "task.transition", t) {
+		return false
+	}
+	if newAssignee != oldAssignee {
+		t.NewAssignee = newAssignee
+		return allow(w, r, "task.assign", t)
+	}
+	return true
+}
+
+// subjectMatches reports whether the caller is one of subject for target.
+func subjectMatches(id *auth.Identity, subject string, t policyTarget) bool {
+	switch subject {
+	case config.SubjectAnyone:
+		return true
+	case config.SubjectUser:
+		return id.Kind == auth.KindUser
+	case config.SubjectAdmin:
+		return id.IsAdmin() || (id.Kind == auth.KindAgent && config.IsPolicyAdmin(id.AgentID))
+	}
+
+	if id.Kind != auth.KindAgent {
+		return false
+	}
+	switch subject {
+	case config.SubjectSelf:
+		return t.Assignee != "" && sameAgent(t.Assignee, id.AgentID)
+	case config.SubjectSubtree:
+		return t.Assignee != "" && config.IsDescendant(t.Assignee, id.AgentID)
+	case config.SubjectTeamLead:
+		me := config.GetAgentByID(id.AgentID)
+		if me == nil || !me.IsLead || me.Team == "" || !strings.EqualFold(t.Team, me.Team) {
+			return false
+		}
+		if t.NewAssignee == "" {
+			return true
+		}
+		na := config.GetAgentByID(t.NewAssignee)
+		if na == nil {
+			na = config.GetAgent(t.NewAssignee)
+		}
+		return na != nil && strings.EqualFold(na.Team, me.Team)
+	}
+	return false
+}
+
+// taskPolicyTarget loads the assignee and team a task's policies are judged
+// against. It returns sql.ErrNoRows for unknown tasks.
+func taskPolicyTarget(taskID string) (policyTarget, error) {
+	var assignee, team sql.NullString
+	if err := db.DB.QueryRow(`SELECT assignee, team FROM tasks WHERE id = $1`, taskID).
+		Scan(&assignee, &team); err != nil {
+		return policyTarget{}, err
+	}
+	return newPolicyTarget(taskID, assignee.String, team.String), nil
+}
+
+// newPolicyTarget builds a target, taking the team from the assignee when
+// the task has none.
+func newPolicyTarget(taskID, assignee, team string) policyTarget {
+	if team == "" && assignee != "" {
+		if ca := config.GetAgentByID(assignee); ca != nil {
+			team = ca.Team
+		} else if ca := config.GetAgent(assignee); ca != nil {
+			team = ca.Team
+		}
+	}
+	return policyTarget{TaskID: taskID, Assignee: assignee, Team: team}
+}
+
+// sameAgent reports whether a stored assignee (ID or name) is the agent.
+func sameAgent(assignee, agentID string) bool {
+	if assignee == agentID {
+		return true
+	}
+	ca := config.GetAgent(assignee)
+	return ca != nil && ca.ID == agentID
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
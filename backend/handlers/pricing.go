@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/config"
+)
+
+type PricingHandler struct{}
+
+// GetPricing handles GET /api/pricing?model=<id>&at=<RFC 3339>
+// Without a model it returns the whole table; with one it returns the rates
+// that apply to that model at the given time (default now).
+func (h *PricingHandler) GetPricing(w http.ResponseWriter, r *http.Request) {
+	model := r.URL.Query().Get("model")
+	if model == "" {
+		respondJSON(w, http.StatusOK, config.GetPricing())
+		return
+	}
+
+	at := time.Now()
+	if s := r.URL.Query().Get("at"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "at must be an RFC 3339 timestamp")
+			return
+		}
+		at = t
+	}
+	respondJSON(w, http.StatusOK, config.PriceFor(model, at))
+}
+
+// priceTokens prices usage at the rates in force for model at the given time.
+func priceTokens(model string, at time.Time, input, output, cacheRead, cacheWrite int64) float64 {
+	p := config.PriceFor(model, at)
+	return (float64(input)*p.Input +
+		float64(output)*p.Output +
+		float64(cacheRead)*p.CacheRead +
+		float64(cacheWrite)*p.CacheWrite) / 1e6
+}
@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"fmt"
+	"html"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/alghanim/agentboard/backend/db"
+
+	"github.com/lib/pq"
 )
 
 // SearchHandler handles global search requests
@@ -13,146 +20,499 @@ type SearchHandler struct{}
 
 // SearchResult represents a unified search result
 type SearchResult struct {
-	Type    string      `json:"type"`    // "task" | "agent" | "comment"
-	ID      string      `json:"id"`
-	Title   string      `json:"title"`
-	Excerpt string      `json:"excerpt"` // 120 chars max, matched text
-	AgentID string      `json:"agent_id,omitempty"`
-	Meta    interface{} `json:"meta,omitempty"` // status for tasks, role for agents
+	Type      string      `json:"type"` // "task" | "agent" | "comment" | "transcript"
+	ID        string      `json:"id"`
+	Title     string      `json:"title"`
+	Excerpt   string      `json:"excerpt"`             // matched text, plain
+	Highlight string      `json:"highlight,omitempty"` // the excerpt as HTML, matches in <mark>
+	AgentID   string      `json:"agent_id,omitempty"`
+	Meta      interface{} `json:"meta,omitempty"` // status for tasks, role for agents
 }
 
-// truncateExcerpt returns at most maxLen characters of s
+// searchGroups are the result groups of /api/search, in response order.
+var searchGroups = []string{"tasks", "agents", "comments", "transcripts"}
+
+// searchHeadlineOptions configures ts_headline. Matches are delimited with
+// control characters rather than markup so the text around them can be
+// escaped (see splitHeadline).
+const searchHeadlineOptions = `StartSel=` + "\x02" + `, StopSel=` + "\x03" +
+	`, MaxWords=30, MinWords=12, MaxFragments=2, FragmentDelimiter=" … "`
+
+// truncateExcerpt returns at most maxLen characters of s, cut on a rune
+// boundary.
 func truncateExcerpt(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
 	}
+	for maxLen > 0 && !utf8.RuneStart(s[maxLen]) {
+		maxLen--
+	}
 	return s[:maxLen]
 }
 
+// splitHeadline turns a ts_headline result into plain text and into HTML
+// with each match wrapped in <mark>.
+func splitHeadline(h string) (text, markup string) {
+	var t, m strings.Builder
+	for h != "" {
+		i := strings.IndexAny(h, "\x02\x03")
+		if i < 0 {
+			i = len(h)
+		}
+		t.WriteString(h[:i])
+		m.WriteString(html.EscapeString(h[:i]))
+		if i == len(h) {
+			break
+		}
+		if h[i] == '\x02' {
+			m.WriteString("<mark>")
+		} else {
+			m.WriteString("</mark>")
+		}
+		h = h[i+1:]
+	}
+	return t.String(), m.String()
+}
+
+// transcriptScope narrows the transcripts group beyond the query string.
+type transcriptScope struct {
+	Agent, Tool  string
+	Since, Until time.Time
+}
+
 // Search handles GET /api/search?q=<query>&limit=20
+// q takes full-text terms, "quoted phrases" and filters (status:, assignee:,
+// label:, priority:, team: for tasks and comments; team: for agents; agent:
+// and tool: for transcripts), each negatable with a leading "-". A group
+// whose filters don't apply to it is empty. limit is per group (max 100);
+// pass a group's next_cursor as cursor, with type set to the group, for its
+// next page. Transcript results can also be narrowed with agent, tool and
+// since/until (RFC 3339).
 func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
-	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	params := r.URL.Query()
+	query := strings.TrimSpace(params.Get("q"))
+
+	limit := 20
+	if v, err := strconv.Atoi(params.Get("limit")); err == nil && v > 0 {
+		limit = v
+		if limit > 100 {
+			limit = 100
+		}
+	}
+	only := params.Get("type")
+	if only != "" && !containsString(searchGroups, only) {
+		respondError(w, http.StatusBadRequest, "type must be one of "+strings.Join(searchGroups, ", "))
+		return
+	}
+	var after *searchCursor
+	if c := params.Get("cursor"); c != "" {
+		if only == "" {
+			respondError(w, http.StatusBadRequest, "cursor requires type")
+			return
+		}
+		var err error
+		if after, err = parseSearchCursor(c); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	scope := transcriptScope{Agent: params.Get("agent"), Tool: params.Get("tool")}
+	for _, p := range []struct {
+		param string
+		dst   *time.Time
+	}{{"since", &scope.Since}, {"until", &scope.Until}} {
+		if v := params.Get(p.param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, p.param+" must be an RFC 3339 timestamp")
+				return
+			}
+			*p.dst = t
+		}
+	}
+
+	resp := make(map[string]interface{}, len(searchGroups)+1)
+	next := make(map[string]string, len(searchGroups))
+	for _, g := range searchGroups {
+		resp[g] = []SearchResult{}
+		next[g] = ""
+	}
+	resp["next_cursor"] = next
 	if query == "" {
-		respondJSON(w, http.StatusOK, map[string]interface{}{
-			"tasks":    []SearchResult{},
-			"agents":   []SearchResult{},
-			"comments": []SearchResult{},
-		})
+		respondJSON(w, http.StatusOK, resp)
 		return
 	}
 
-	limitStr := r.URL.Query().Get("limit")
-	limit := 20
-	if limitStr != "" {
-		if v, err := strconv.Atoi(limitStr); err == nil && v > 0 {
-			limit = v
-		}
-	}
-
-	pattern := "%" + query + "%"
-	var results []SearchResult
-
-	// --- Tasks ---
-	taskRows, err := db.DB.Query(`
-		SELECT id::text, title, COALESCE(description,''), COALESCE(status,''), COALESCE(assignee,'')
-		FROM tasks
-		WHERE title ILIKE $1 OR description ILIKE $1
-		LIMIT $2
-	`, pattern, limit)
-	if err == nil {
-		defer taskRows.Close()
-		for taskRows.Next() {
-			var id, title, description, status, assignee string
-			if err := taskRows.Scan(&id, &title, &description, &status, &assignee); err != nil {
-				continue
-			}
-			excerpt := truncateExcerpt(description, 120)
-			if excerpt == "" {
-				excerpt = truncateExcerpt(title, 120)
-			}
-			results = append(results, SearchResult{
-				Type:    "task",
-				ID:      id,
-				Title:   title,
-				Excerpt: excerpt,
-				AgentID: assignee,
-				Meta:    status,
-			})
-		}
-	}
-
-	// --- Agents ---
-	agentRows, err := db.DB.Query(`
-		SELECT id::text, COALESCE(display_name, id), COALESCE(role,''), COALESCE(team,'')
-		FROM agents
-		WHERE display_name ILIKE $1 OR role ILIKE $1
-		LIMIT $2
-	`, pattern, limit)
-	if err == nil {
-		defer agentRows.Close()
-		for agentRows.Next() {
-			var id, name, role, team string
-			if err := agentRows.Scan(&id, &name, &role, &team); err != nil {
-				continue
-			}
-			results = append(results, SearchResult{
-				Type:    "agent",
-				ID:      id,
-				Title:   name,
-				Excerpt: truncateExcerpt(role, 120),
-				Meta:    map[string]string{"role": role, "team": team},
-			})
-		}
-	}
-
-	// --- Comments ---
-	commentRows, err := db.DB.Query(`
-		SELECT c.id::text, c.content, c.task_id::text, t.title as task_title
-		FROM comments c
-		JOIN tasks t ON c.task_id = t.id
-		WHERE c.content ILIKE $1
-		LIMIT $2
-	`, pattern, limit)
-	if err == nil {
-		defer commentRows.Close()
-		for commentRows.Next() {
-			var id, content, taskID, taskTitle string
-			if err := commentRows.Scan(&id, &content, &taskID, &taskTitle); err != nil {
-				continue
+	sq := parseSearchQuery(query)
+	for _, g := range searchGroups {
+		if only != "" && g != only {
+			continue
+		}
+		var results []SearchResult
+		var nc *searchCursor
+		var err error
+		switch g {
+		case "tasks":
+			results, nc, err = searchTasks(sq, after, limit)
+		case "agents":
+			results, nc, err = searchAgents(sq, after, limit)
+		case "comments":
+			results, nc, err = searchComments(sq, after, limit)
+		case "transcripts":
+			results, nc, err = searchTranscripts(sq, scope, after, limit)
+		}
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		resp[g] = results
+		if nc != nil {
+			next[g] = nc.String()
+		}
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// searchSource describes a table searched by full text.
+type searchSource struct {
+	from   string // FROM clause
+	id     string // unique ID column, the tie-breaker
+	idType string // its SQL type
+	vector string // tsvector column
+	time   string // orders results when the query has no text terms
+	doc    string // text the highlighted excerpt is drawn from
+	cols   string // columns returned for each result
+}
+
+// rankedQuery builds the query for one page of s: rows matching the text
+// terms and conds, by ts_rank (newest first when there are no terms). Each
+// row has s.cols, then the sort key, the ID as text and the ts_headline of
+// s.doc ("" without terms).
+func rankedQuery(args *sqlArgs, q searchQuery, s searchSource, conds []string, after *searchCursor, limit int) string {
+	from, key, headline := s.from, "EXTRACT(EPOCH FROM "+s.time+")::float8", "''"
+	if tsq := q.tsquery(args); tsq != "" {
+		from += ", (SELECT " + tsq + " AS q) sq"
+		conds = append(conds, s.vector+" @@ sq.q")
+		key = "ts_rank(" + s.vector + ", sq.q)::float8"
+		headline = "ts_headline('english', " + s.doc + ", sq.q, " + args.add(searchHeadlineOptions) + ")"
+	}
+	if c := after.after(args, key, s.id, s.idType); c != "" {
+		conds = append(conds, c)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+	// ts_headline is only evaluated for the rows that survive the LIMIT.
+	return "SELECT " + s.cols + ", " + key + ", " + s.id + "::text, " + headline +
+		" FROM " + from + where +
+		" ORDER BY " + key + " DESC, " + s.id + " DESC LIMIT " + args.add(limit+1)
+}
+
+// filterConds returns the SQL conditions for q's filters on the given keys,
+// each mapped to the column it matches. Values match case-insensitively,
+// except labels, where the column is a text[] and any listed label matches.
+func filterConds(args *sqlArgs, q searchQuery, columns map[string]string) []string {
+	var conds []string
+	for _, f := range q.Filters {
+		col, ok := columns[f.Key]
+		if !ok {
+			continue
+		}
+		var cond string
+		if f.Key == "label" {
+			cond = "COALESCE(" + col + ", '{}') && " + args.add(pq.Array(f.Values)) + "::text[]"
+		} else {
+			values := make([]string, len(f.Values))
+			for i, v := range f.Values {
+				values[i] = strings.ToLower(v)
 			}
-			results = append(results, SearchResult{
-				Type:    "comment",
-				ID:      id,
-				Title:   taskTitle,
-				Excerpt: truncateExcerpt(content, 120),
-				Meta:    map[string]string{"task_id": taskID},
-			})
+			cond = "LOWER(COALESCE(" + col + ", '')) = ANY(" + args.add(pq.Array(values)) + "::text[])"
+		}
+		if f.Negate {
+			cond = "NOT (" + cond + ")"
+		}
+		conds = append(conds, cond)
+	}
+	return conds
+}
+
+// taskFilterColumns maps the task filters onto a tasks row aliased t.
+var taskFilterColumns = map[string]string{
+	"status":   "t.status",
+	"assignee": "t.assignee",
+	"label":    "t.labels",
+	"priority": "t.priority",
+	"team":     "t.team",
+}
+
+// likeEscaper escapes the ILIKE wildcards in a user's text; patterns built
+// from it must be compared with ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// pageResults trims a limit+1 result set to limit and returns the cursor
+// for the rest, if any.
+func pageResults(results []SearchResult, keys []float64, ids []string, limit int) ([]SearchResult, *searchCursor) {
+	if len(results) <= limit {
+		return results, nil
+	}
+	return results[:limit], &searchCursor{Key: keys[limit-1], ID: ids[limit-1]}
+}
+
+func searchTasks(q searchQuery, after *searchCursor, limit int) ([]SearchResult, *searchCursor, error) {
+	results := []SearchResult{}
+	if !q.supports("status", "assignee", "label", "priority", "team") {
+		return results, nil, nil
+	}
+	var args sqlArgs
+	sql := rankedQuery(&args, q, searchSource{
+		from:   "tasks t",
+		id:     "t.id",
+		idType: "uuid",
+		vector: "t.search",
+		time:   "t.updated_at",
+		doc:    "COALESCE(NULLIF(t.description, ''), t.title)",
+		cols:   "t.id::text, t.title, COALESCE(t.description, ''), COALESCE(t.status, ''), COALESCE(t.assignee, '')",
+	}, filterConds(&args, q, taskFilterColumns), after, limit)
+
+	rows, err := db.DB.Query(sql, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var keys []float64
+	var ids []string
+	for rows.Next() {
+		var id, title, description, status, assignee, sortID, headline string
+		var key float64
+		if err := rows.Scan(&id, &title, &description, &status, &assignee, &key, &sortID, &headline); err != nil {
+			return nil, nil, err
+		}
+		res := SearchResult{Type: "task", ID: id, Title: title, AgentID: assignee, Meta: status}
+		if headline != "" {
+			res.Excerpt, res.Highlight = splitHeadline(headline)
+		} else if res.Excerpt = truncateExcerpt(description, 120); res.Excerpt == "" {
+			res.Excerpt = truncateExcerpt(title, 120)
 		}
+		results = append(results, res)
+		keys, ids = append(keys, key), append(ids, sortID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
 	}
+	results, next := pageResults(results, keys, ids, limit)
+	return results, next, nil
+}
+
+func searchComments(q searchQuery, after *searchCursor, limit int) ([]SearchResult, *searchCursor, error) {
+	results := []SearchResult{}
+	if !q.supports("status", "assignee", "label", "priority", "team") {
+		return results, nil, nil
+	}
+	var args sqlArgs
+	sql := rankedQuery(&args, q, searchSource{
+		from:   "comments c JOIN tasks t ON t.id = c.task_id",
+		id:     "c.id",
+		idType: "uuid",
+		vector: "c.search",
+		time:   "c.created_at",
+		doc:    "c.content",
+		cols:   "c.id::text, c.content, c.task_id::text, t.title",
+	}, filterConds(&args, q, taskFilterColumns), after, limit)
 
-	// Limit total results to 20
-	if len(results) > limit {
-		results = results[:limit]
+	rows, err := db.DB.Query(sql, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var keys []float64
+	var ids []string
+	for rows.Next() {
+		var id, content, taskID, taskTitle, sortID, headline string
+		var key float64
+		if err := rows.Scan(&id, &content, &taskID, &taskTitle, &key, &sortID, &headline); err != nil {
+			return nil, nil, err
+		}
+		res := SearchResult{Type: "comment", ID: id, Title: taskTitle, Meta: map[string]string{"task_id": taskID}}
+		if headline != "" {
+			res.Excerpt, res.Highlight = splitHeadline(headline)
+		} else {
+			res.Excerpt = truncateExcerpt(content, 120)
+		}
+		results = append(results, res)
+		keys, ids = append(keys, key), append(ids, sortID)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	results, next := pageResults(results, keys, ids, limit)
+	return results, next, nil
+}
 
-	// Group by type for the response
-	tasks := []SearchResult{}
-	agents := []SearchResult{}
-	comments := []SearchResult{}
-	for _, sr := range results {
-		switch sr.Type {
-		case "task":
-			tasks = append(tasks, sr)
-		case "agent":
-			agents = append(agents, sr)
-		case "comment":
-			comments = append(comments, sr)
+// searchAgents matches the text terms against agent names, IDs and roles.
+// There are few agents, so this is a substring match ranked by how many
+// terms hit the name (weighted double) or role.
+func searchAgents(q searchQuery, after *searchCursor, limit int) ([]SearchResult, *searchCursor, error) {
+	results := []SearchResult{}
+	if !q.supports("team") {
+		return results, nil, nil
+	}
+	var args sqlArgs
+	conds := filterConds(&args, q, map[string]string{"team": "team"})
+	key := "0"
+	for _, t := range q.Terms {
+		p := args.add("%"+likeEscaper.Replace(t.Text)+"%") + ` ESCAPE '\'`
+		name := "(COALESCE(display_name, '') ILIKE " + p + " OR id ILIKE " + p + ")"
+		role := "COALESCE(role, '') ILIKE " + p
+		if t.Negate {
+			conds = append(conds, "NOT "+name, "NOT "+role)
+			continue
 		}
+		conds = append(conds, "("+name+" OR "+role+")")
+		key += " + " + name + "::int * 2 + (" + role + ")::int"
 	}
+	key = "(" + key + ")::float8"
+	if c := after.after(&args, key, "id", "text"); c != "" {
+		conds = append(conds, c)
+	}
+	sql := "SELECT id, COALESCE(display_name, id), COALESCE(role, ''), COALESCE(team, ''), " + key + " FROM agents"
+	if len(conds) > 0 {
+		sql += " WHERE " + strings.Join(conds, " AND ")
+	}
+	sql += " ORDER BY " + key + " DESC, id DESC LIMIT " + args.add(limit+1)
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"tasks":    tasks,
-		"agents":   agents,
-		"comments": comments,
-	})
+	rows, err := db.DB.Query(sql, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var keys []float64
+	var ids []string
+	for rows.Next() {
+		var id, name, role, team string
+		var key float64
+		if err := rows.Scan(&id, &name, &role, &team, &key); err != nil {
+			return nil, nil, err
+		}
+		results = append(results, SearchResult{
+			Type:    "agent",
+			ID:      id,
+			Title:   name,
+			Excerpt: truncateExcerpt(role, 120),
+			Meta:    map[string]string{"role": role, "team": team},
+		})
+		keys, ids = append(keys, key), append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	results, next := pageResults(results, keys, ids, limit)
+	return results, next, nil
+}
+
+// searchTranscripts searches transcript_entries. Each result links to its
+// place in the transcript API.
+func searchTranscripts(q searchQuery, scope transcriptScope, after *searchCursor, limit int) ([]SearchResult, *searchCursor, error) {
+	results := []SearchResult{}
+	if !q.supports("agent", "tool") {
+		return results, nil, nil
+	}
+	// agent: filters and the agent param take an ID or a display name.
+	filters := make([]searchFilter, len(q.Filters))
+	for i, f := range q.Filters {
+		filters[i] = f
+		if f.Key != "agent" {
+			continue
+		}
+		filters[i].Values = make([]string, len(f.Values))
+		for j, v := range f.Values {
+			filters[i].Values[j] = v
+			if ca := resolveAgent(v); ca != nil {
+				filters[i].Values[j] = ca.ID
+			}
+		}
+	}
+	q.Filters = filters
+	var args sqlArgs
+	conds := filterConds(&args, q, map[string]string{"agent": "e.agent_id", "tool": "e.tool_name"})
+	if scope.Agent != "" {
+		agent := scope.Agent
+		if ca := resolveAgent(agent); ca != nil {
+			agent = ca.ID
+		}
+		conds = append(conds, "e.agent_id = "+args.add(agent))
+	}
+	if scope.Tool != "" {
+		conds = append(conds, "e.tool_name ILIKE "+args.add(likeEscaper.Replace(scope.Tool))+` ESCAPE '\'`)
+	}
+	if !scope.Since.IsZero() {
+		conds = append(conds, "e.ts >= "+args.add(scope.Since))
+	}
+	if !scope.Until.IsZero() {
+		conds = append(conds, "e.ts < "+args.add(scope.Until))
+	}
+	sql := rankedQuery(&args, q, searchSource{
+		from:   "transcript_entries e",
+		id:     "e.id",
+		idType: "bigint",
+		vector: "e.search",
+		time:   "e.ts",
+		doc:    "e.content",
+		cols: "e.agent_id, e.session_key, e.line_offset, e.entry_index, e.ts, e.role, e.type, " +
+			"COALESCE(e.tool_name, ''), e.is_error, e.content",
+	}, conds, after, limit)
+
+	rows, err := db.DB.Query(sql, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var keys []float64
+	var ids []string
+	for rows.Next() {
+		var lineOffset int64
+		var entryIndex int
+		var agentID, sessionKey, role, entryType, toolName, content, sortID, headline string
+		var ts time.Time
+		var isError bool
+		var key float64
+		if err := rows.Scan(&agentID, &sessionKey, &lineOffset, &entryIndex, &ts, &role, &entryType,
+			&toolName, &isError, &content, &key, &sortID, &headline); err != nil {
+			return nil, nil, err
+		}
+		cursor := transcriptCursor{offset: lineOffset, index: entryIndex}.String()
+		title := sessionKey
+		if toolName != "" {
+			title = toolName + " · " + sessionKey
+		}
+		res := SearchResult{
+			Type:    "transcript",
+			ID:      sortID,
+			Title:   title,
+			AgentID: agentID,
+			Meta: map[string]interface{}{
+				"session_key": sessionKey,
+				"line_offset": lineOffset,
+				"entry_index": entryIndex,
+				"timestamp":   ts,
+				"role":        role,
+				"type":        entryType,
+				"tool_name":   toolName,
+				"is_error":    isError,
+				"transcript":  fmt.Sprintf("/api/agents/%s/sessions/%s/transcript?cursor=%s", url.PathEscape(agentID), url.PathEscape(sessionKey), cursor),
+			},
+		}
+		if headline != "" {
+			res.Excerpt, res.Highlight = splitHeadline(headline)
+		} else {
+			res.Excerpt = truncateExcerpt(content, 120)
+		}
+		results = append(results, res)
+		keys, ids = append(keys, key), append(ids, sortID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	results, next := pageResults(results, keys, ids, limit)
+	return results, next, nil
 }
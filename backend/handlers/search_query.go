@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// searchQuery is a parsed /api/search query string such as
+//
+//	status:review assignee:forge label:api "exact phrase" -wontfix
+//
+// Bare words and quoted phrases are full-text terms; key:value pairs with a
+// known key are filters. A leading "-" negates either. Filter values may be
+// quoted and may list alternatives separated by commas (status:todo,review).
+type searchQuery struct {
+	Terms   []searchTerm
+	Filters []searchFilter
+}
+
+type searchTerm struct {
+	Text   string
+	Phrase bool
+	Negate bool
+}
+
+type searchFilter struct {
+	Key    string
+	Values []string
+	Negate bool
+}
+
+// searchFilterKeys are the keys recognised as filters; any other key:value
+// token is searched as text.
+var searchFilterKeys = map[string]bool{
+	"status":   true,
+	"assignee": true,
+	"label":    true,
+	"priority": true,
+	"team":     true,
+	"agent":    true,
+	"tool":     true,
+}
+
+// parseSearchQuery splits q into full-text terms and filters. It never
+// fails: an unterminated quote runs to the end of the string.
+func parseSearchQuery(q string) searchQuery {
+	var sq searchQuery
+	rs := []rune(q)
+	i := 0
+
+	// word reads up to the next space, or a quoted string if one starts here.
+	word := func() (string, bool) {
+		if i < len(rs) && rs[i] == '"' {
+			i++
+			start := i
+			for i < len(rs) && rs[i] != '"' {
+				i++
+			}
+			w := string(rs[start:i])
+			if i < len(rs) {
+				i++ // closing quote
+			}
+			return w, true
+		}
+		start := i
+		for i < len(rs) && !unicode.IsSpace(rs[i]) {
+			i++
+		}
+		return string(rs[start:i]), false
+	}
+
+	for i < len(rs) {
+		if unicode.IsSpace(rs[i]) {
+			i++
+			continue
+		}
+		negate := false
+		if rs[i] == '-' && i+1 < len(rs) && !unicode.IsSpace(rs[i+1]) {
+			negate = true
+			i++
+		}
+
+		// key:value, possibly key:"quoted value"
+		if rs[i] != '"' {
+			j := i
+			for j < len(rs) && rs[j] != ':' && !unicode.IsSpace(rs[j]) && rs[j] != '"' {
+				j++
+			}
+			key := strings.ToLower(string(rs[i:j]))
+			if j < len(rs) && rs[j] == ':' && searchFilterKeys[key] {
+				i = j + 1
+				value, _ := word()
+				var values []string
+				for _, v := range strings.Split(value, ",") {
+					if v = strings.TrimSpace(v); v != "" {
+						values = append(values, v)
+					}
+				}
+				if len(values) > 0 {
+					sq.Filters = append(sq.Filters, searchFilter{Key: key, Values: values, Negate: negate})
+				}
+				continue
+			}
+		}
+
+		text, phrase := word()
+		if text = strings.TrimSpace(text); text != "" {
+			sq.Terms = append(sq.Terms, searchTerm{Text: text, Phrase: phrase, Negate: negate})
+		}
+	}
+	return sq
+}
+
+// supports reports whether every filter in the query is one of keys; a
+// result group that can't apply a filter returns nothing rather than
+// ignoring it.
+func (q searchQuery) supports(keys ...string) bool {
+	for _, f := range q.Filters {
+		found := false
+		for _, k := range keys {
+			if f.Key == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// sqlArgs accumulates positional query parameters.
+type sqlArgs []interface{}
+
+// add appends v and returns its placeholder.
+func (a *sqlArgs) add(v interface{}) string {
+	*a = append(*a, v)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// tsquery returns a tsquery expression matching every term, or "" when the
+// query has no full-text terms. Terms are stemmed by plainto_tsquery; phrases
+// use phraseto_tsquery so their words must be adjacent and in order.
+func (q searchQuery) tsquery(args *sqlArgs) string {
+	var parts []string
+	for _, t := range q.Terms {
+		fn := "plainto_tsquery"
+		if t.Phrase {
+			fn = "phraseto_tsquery"
+		}
+		expr := fn + "('english', " + args.add(t.Text) + ")"
+		if t.Negate {
+			expr = "(!! " + expr + ")"
+		}
+		parts = append(parts, expr)
+	}
+	return strings.Join(parts, " && ")
+}
+
+// searchCursor marks the last result of a page: its sort key (rank, or a
+// timestamp for filter-only queries) and ID.
+type searchCursor struct {
+	Key float64
+	ID  string
+}
+
+func (c searchCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatFloat(c.Key, 'g', -1, 64) + " " + c.ID))
+}
+
+func parseSearchCursor(s string) (*searchCursor, error) {
+	errInvalid := errors.New("invalid cursor")
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalid
+	}
+	key, id, ok := strings.Cut(string(b), " ")
+	if !ok || id == "" {
+		return nil, errInvalid
+	}
+	k, err := strconv.ParseFloat(key, 64)
+	if err != nil {
+		return nil, errInvalid
+	}
+	return &searchCursor{Key: k, ID: id}, nil
+}
+
+// after returns a condition selecting the rows that sort after c when
+// ordering by keyExpr DESC, idExpr DESC, or "" when c is nil.
+func (c *searchCursor) after(args *sqlArgs, keyExpr, idExpr, idType string) string {
+	if c == nil {
+		return ""
+	}
+	return "(" + keyExpr + ", " + idExpr + ") < (" + args.add(c.Key) + "::float8, " + args.add(c.ID) + "::" + idType + ")"
+}
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/config"
+
+	"github.com/gorilla/mux"
+)
+
+// SessionSummary describes one session transcript file.
+type SessionSummary struct {
+	SessionKey       string     `json:"session_key"`
+	AgentID          string     `json:"agent_id"`
+	Status           string     `json:"status"` // running, completed or failed
+	StartedAt        *time.Time `json:"started_at"`
+	EndedAt          *time.Time `json:"ended_at"`
+	Model            string     `json:"model,omitempty"`
+	Messages         int        `json:"messages"`
+	ToolCalls        int        `json:"tool_calls"`
+	ToolErrors       int        `json:"tool_errors"`
+	InputTokens      int64      `json:"input_tokens"`
+	OutputTokens     int64      `json:"output_tokens"`
+	CacheReadTokens  int64      `json:"cache_read_tokens"`
+	CacheWriteTokens int64      `json:"cache_write_tokens"`
+	TotalTokens      int64      `json:"total_tokens"`
+	Cost             float64    `json:"cost"`
+	SizeBytes        int64      `json:"size_bytes"`
+}
+
+// sessionIndex caches a summary per session file and updates it from the
+// bytes appended since it was last read, so listing sessions doesn't rescan
+// whole transcripts.
+type sessionIndex struct {
+	mu    sync.Mutex
+	files map[string]*indexedSession // by path
+}
+
+type indexedSession struct {
+	offset  fileOffset
+	summary SessionSummary
+}
+
+var sessionSummaries = &sessionIndex{files: make(map[string]*indexedSession)}
+
+// summarize returns the current summary of a session file and when it was
+// last written.
+func (ix *sessionIndex) summarize(path, agentID string) (SessionSummary, time.Time, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	s, ok := ix.files[path]
+	if !ok {
+		s = &indexedSession{summary: SessionSummary{
+			SessionKey: strings.TrimSuffix(filepath.Base(path), ".jsonl"),
+			AgentID:    agentID,
+		}}
+	}
+	lines, next, reset, err := readAppended(path, s.offset)
+	if err != nil {
+		return SessionSummary{}, time.Time{}, err
+	}
+	if reset {
+		s.summary = SessionSummary{SessionKey: s.summary.SessionKey, AgentID: agentID}
+	}
+	for _, line := range lines {
+		s.summary.add(line, agentID)
+	}
+	s.offset = next
+	s.summary.SizeBytes = next.Size
+	ix.files[path] = s
+	return s.summary, next.ModTime, nil
+}
+
+// add folds one transcript line into the summary.
+func (s *SessionSummary) add(line []byte, agentID string) {
+	var entry map[string]interface{}
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return
+	}
+	if ts, ok := entryTime(entry); ok {
+		if s.StartedAt == nil || ts.Before(*s.StartedAt) {
+			s.StartedAt = &ts
+		}
+		if s.EndedAt == nil || ts.After(*s.EndedAt) {
+			s.EndedAt = &ts
+		}
+	}
+	if entry["type"] != "message" {
+		return
+	}
+	msg, ok := entry["message"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	s.Messages++
+
+	switch role, _ := msg["role"].(string); role {
+	case "assistant":
+		if content, ok := msg["content"].([]interface{}); ok {
+			for _, block := range content {
+				if bm, ok := block.(map[string]interface{}); ok && (bm["type"] == "toolCall" || bm["type"] == "tool_use") {
+					s.ToolCalls++
+				}
+			}
+		}
+		if m, ok := msg["model"].(string); ok && m != "" {
+			s.Model = m
+		}
+		if u, ok := parseTokenLine(line, agentID); ok {
+			s.InputTokens += u.Input
+			s.OutputTokens += u.Output
+			s.CacheReadTokens += u.CacheRead
+			s.CacheWriteTokens += u.CacheWrite
+			total := u.TotalTokens
+			if total == 0 {
+				total = u.Input + u.Output + u.CacheRead + u.CacheWrite
+			}
+			s.TotalTokens += total
+			s.Cost += u.Cost()
+		}
+	case "toolResult", "tool":
+		if isErr, _ := msg["isError"].(bool); isErr {
+			s.ToolErrors++
+		}
+	}
+}
+
+// agentSessionFiles returns the agent's session transcripts, keyed by
+// session key, across its session directories.
+func agentSessionFiles(agent OCAgent) map[string]string {
+	files := make(map[string]string)
+	for _, dirName := range getSessionDirs(agent) {
+		dir := filepath.Join(config.GetOpenClawDir(), "agents", dirName, "sessions")
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+				continue
+			}
+			key := strings.TrimSuffix(e.Name(), ".jsonl")
+			if _, dup := files[key]; !dup {
+				files[key] = filepath.Join(dir, e.Name())
+			}
+		}
+	}
+	return files
+}
+
+// resolveAgent looks an agent up by ID or name.
+func resolveAgent(idOrName string) *config.Agent {
+	if ca := config.GetAgentByID(idOrName); ca != nil {
+		return ca
+	}
+	return config.GetAgent(idOrName)
+}
+
+// GetAgentSessions handles GET /api/agents/{id}/sessions
+// Lists every session transcript of the agent, most recent first. limit
+// defaults to 100 (max 1000); offset pages further back.
+func (h *OpenClawHandler) GetAgentSessions(w http.ResponseWriter, r *http.Request) {
+	ca := resolveAgent(mux.Vars(r)["id"])
+	if ca == nil {
+		respondError(w, http.StatusNotFound, "Agent not found")
+		return
+	}
+	q := r.URL.Query()
+	limit, offset := 100, 0
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 && v <= 1000 {
+		limit = v
+	}
+	if v, err := strconv.Atoi(q.Get("offset")); err == nil && v > 0 {
+		offset = v
+	}
+
+	agent := agentFromConfig(*ca)
+	aborted := map[string]bool{}
+	for _, dirName := range getSessionDirs(agent) {
+		for id := range abortedSessions(filepath.Join(config.GetOpenClawDir(), "agents", dirName, "sessions", "sessions.json")) {
+			aborted[id] = true
+		}
+	}
+
+	sessions := []SessionSummary{}
+	for key, path := range agentSessionFiles(agent) {
+		s, modTime, err := sessionSummaries.summarize(path, ca.ID)
+		if err != nil {
+			continue
+		}
+		s.Status = "completed"
+		switch {
+		case aborted[key]:
+			s.Status = "failed"
+		case time.Since(modTime) < sessionIdleAfter:
+			s.Status = "running"
+		}
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessionSortTime(sessions[i]).After(sessionSortTime(sessions[j]))
+	})
+
+	total := len(sessions)
+	if offset > total {
+		offset = total
+	}
+	sessions = sessions[offset:]
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"agent_id": ca.ID,
+		"total":    total,
+		"sessions": sessions,
+	})
+}
+
+func sessionSortTime(s SessionSummary) time.Time {
+	if s.EndedAt != nil {
+		return *s.EndedAt
+	}
+	return time.Time{}
+}
+
+// GetSessionTranscript handles GET /api/agents/{id}/sessions/{sessionKey}/transcript
+// Pages through a whole transcript, oldest first, with untruncated content
+// and tool arguments. Filters: role (user, assistant or tool), tool (tool
+// name) and errors=true (failed tool results only). limit defaults to 100
+// (max 1000); pass next_cursor back as cursor for the following page.
+func (h *OpenClawHandler) GetSessionTranscript(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	ca := resolveAgent(vars["id"])
+	if ca == nil {
+		respondError(w, http.StatusNotFound, "Agent not found")
+		return
+	}
+	key := vars["sessionKey"]
+	path, ok := agentSessionFiles(agentFromConfig(*ca))[key]
+	if !ok {
+		respondError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	q := r.URL.Query()
+	limit := 100
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 && v <= 1000 {
+		limit = v
+	}
+	cursor, err := parseTranscriptCursor(q.Get("cursor"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	role := q.Get("role")
+	switch role {
+	case "", "user", "assistant", "tool":
+	default:
+		respondError(w, http.StatusBadRequest, "role must be user, assistant or tool")
+		return
+	}
+	tool := q.Get("tool")
+	errorsOnly := q.Get("errors") == "true"
+	match := func(e OCStreamEntry) bool {
+		if role != "" && transcriptRole(e.Type) != role {
+			return false
+		}
+		if tool != "" && !strings.EqualFold(e.ToolName, tool) {
+			return false
+		}
+		if errorsOnly && !e.IsError && (e.ExitCode == nil || *e.ExitCode == 0) {
+			return false
+		}
+		return true
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer f.Close()
+	if _, err := f.Seek(cursor.offset, io.SeekStart); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+
+	agent := agentFromConfig(*ca)
+	entries := []TranscriptEntry{}
+	reader := bufio.NewReaderSize(f, 64*1024)
+	pos, skip := cursor.offset, cursor.index
+	var next string
+	for next == "" {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			break // EOF, or a line still being written
+		}
+		lineStart := pos
+		pos += int64(len(line))
+
+		var entry map[string]interface{}
+		if json.Unmarshal(line, &entry) != nil {
+			continue
+		}
+		for i, e := range parseStreamEntries(entry, agent, true) {
+			if i < skip || !match(e) {
+				continue
+			}
+			if len(entries) == limit {
+				next = transcriptCursor{offset: lineStart, index: i}.String()
+				break
+			}
+			entries = append(entries, TranscriptEntry{
+				Timestamp: e.Timestamp,
+				Role:      transcriptRole(e.Type),
+				Type:      e.Type,
+				Content:   e.Content,
+				ToolName:  e.ToolName,
+				ToolInput: e.ToolInput,
+				ExitCode:  e.ExitCode,
+				IsError:   e.IsError,
+			})
+		}
+		skip = 0
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"agent_id":    ca.ID,
+		"session_key": key,
+		"entries":     entries,
+		"next_cursor": next,
+	})
+}
+
+// TranscriptEntry is one untruncated event from a session transcript.
+type TranscriptEntry struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Role      string                 `json:"role"` // user, assistant or tool
+	Type      string                 `json:"type"` // prompt, response, command or result
+	Content   string                 `json:"content"`
+	ToolName  string                 `json:"tool_name,omitempty"`
+	ToolInput map[string]interface{} `json:"tool_input,omitempty"`
+	ExitCode  *int                   `json:"exit_code,omitempty"`
+	IsError   bool                   `json:"is_error,omitempty"`
+}
+
+// transcriptRole maps a stream entry type to who produced it.
+func transcriptRole(entryType string) string {
+	switch entryType {
+	case "prompt":
+		return "user"
+	case "result":
+		return "tool"
+	default:
+		return "assistant"
+	}
+}
+
+// transcriptCursor points at an entry within a transcript: the byte offset
+// of its line and its position among the entries parsed from that line.
+type transcriptCursor struct {
+	offset int64
+	index  int
+}
+
+func (c transcriptCursor) String() string {
+	return fmt.Sprintf("%d.%d", c.offset, c.index)
+}
+
+func parseTranscriptCursor(s string) (transcriptCursor, error) {
+	if s == "" {
+		return transcriptCursor{}, nil
+	}
+	off, idx, ok := strings.Cut(s, ".")
+	if !ok {
+		return transcriptCursor{}, fmt.Errorf("malformed cursor %q", s)
+	}
+	var c transcriptCursor
+	var err error
+	if c.offset, err = strconv.ParseInt(off, 10, 64); err != nil || c.offset < 0 {
+		return transcriptCursor{}, fmt.Errorf("malformed cursor %q", s)
+	}
+	if c.index, err = strconv.Atoi(idx); err != nil || c.index < 0 {
+		return transcriptCursor{}, fmt.Errorf("malformed cursor %q", s)
+	}
+	return c, nil
+}
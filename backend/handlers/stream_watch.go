@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/config"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// streamRecentPerAgent is how many stream entries are kept in memory per
+// agent for GET /api/openclaw/stream (its limit tops out at 200).
+const streamRecentPerAgent = 200
+
+// streamRescanInterval is how often the watcher rescans the session
+// directories for anything inotify missed (queue overflow, directories
+// created before they could be watched).
+const streamRescanInterval = time.Minute
+
+// transcriptTail follows the session JSONL files under
+// {openclaw_dir}/agents/*/sessions/. fsnotify reports every write, only the
+// appended lines are parsed, and each resulting stream entry is broadcast
+// and kept in a per-agent ring that the stream endpoint serves from. The
+// same events drive agent status detection (see agentStatusTracker).
+type transcriptTail struct {
+	// offsets is owned by the watcher goroutine.
+	offsets map[string]fileOffset
+
+	mu      sync.RWMutex
+	running bool
+	recent  map[string][]OCStreamEntry // agent ID → entries, oldest first
+}
+
+var transcripts = &transcriptTail{
+	offsets: make(map[string]fileOffset),
+	recent:  make(map[string][]OCStreamEntry),
+}
+
+// StartTranscriptWatcher runs in a goroutine and broadcasts a stream_entry
+// for every transcript line agents append. If no watcher can be created the
+// stream endpoint keeps reading the files on each request.
+func StartTranscriptWatcher(hub broadcaster) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("[stream] file watcher unavailable, falling back to polling: %v", err)
+		return
+	}
+	defer w.Close()
+
+	agentsDir := filepath.Join(config.GetOpenClawDir(), "agents")
+	transcripts.seed()
+	transcripts.scan(w, agentsDir, nil)
+
+	transcripts.mu.Lock()
+	transcripts.running = true
+	transcripts.mu.Unlock()
+
+	ticker := time.NewTicker(streamRescanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			transcripts.handle(w, hub, agentsDir, ev)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			// Usually an inotify overflow: events were lost, so catch up.
+			log.Printf("[stream] watcher: %v", err)
+			transcripts.scan(w, agentsDir, hub)
+		case <-ticker.C:
+			transcripts.scan(w, agentsDir, hub)
+		}
+	}
+}
+
+// seed fills the rings from the tail of each agent's latest transcript, as
+// the stream endpoint used to return.
+func (t *transcriptTail) seed() {
+	for _, ca := range config.GetAgents() {
+		agent := agentFromConfig(ca)
+		latestJSONL, _ := findLatestJSONLForAgent(agent)
+		if latestJSONL == "" {
+			continue
+		}
+		var entries []OCStreamEntry
+		for _, entry := range readLastJSONLEntries(latestJSONL, 20*1024) {
+			entries = append(entries, parseJSONLToStream(entry, agent)...)
+		}
+		t.add(ca.ID, entries)
+	}
+}
+
+// scan watches every agent and sessions directory and reads what was
+// appended to each transcript since it was last seen. A nil hub marks the
+// initial scan: existing content is skipped rather than broadcast.
+func (t *transcriptTail) scan(w *fsnotify.Watcher, agentsDir string, hub broadcaster) {
+	if err := w.Add(agentsDir); err != nil {
+		return
+	}
+	dirs, err := os.ReadDir(agentsDir)
+	if err != nil {
+		return
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		agentDir := filepath.Join(agentsDir, d.Name())
+		w.Add(agentDir) // to notice sessions/ being created
+		t.scanSessions(w, filepath.Join(agentDir, "sessions"), hub)
+	}
+}
+
+func (t *transcriptTail) scanSessions(w *fsnotify.Watcher, sessionsDir string, hub broadcaster) {
+	if err := w.Add(sessionsDir); err != nil {
+		return
+	}
+	files, err := os.ReadDir(sessionsDir)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".jsonl") {
+			continue
+		}
+		path := filepath.Join(sessionsDir, f.Name())
+		if hub == nil {
+			if o, err := endOffset(path); err == nil {
+				t.offsets[path] = o
+			}
+			continue
+		}
+		t.tail(path, hub)
+	}
+}
+
+// handle reacts to one filesystem event.
+func (t *transcriptTail) handle(w *fsnotify.Watcher, hub broadcaster, agentsDir string, ev fsnotify.Event) {
+	if sessionsDir := filepath.Dir(ev.Name); filepath.Base(sessionsDir) == "sessions" && filepath.Dir(filepath.Dir(sessionsDir)) == agentsDir {
+		// Any write to sessions.json or a transcript may change the status.
+		agentStatuses.touch(agentIDForDir(filepath.Base(filepath.Dir(sessionsDir))))
+	}
+	if strings.HasSuffix(ev.Name, ".jsonl") {
+		switch {
+		case ev.Has(fsnotify.Write), ev.Has(fsnotify.Create):
+			t.tail(ev.Name, hub)
+		case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+			delete(t.offsets, ev.Name)
+		}
+		return
+	}
+	if !ev.Has(fsnotify.Create) {
+		return
+	}
+	if info, err := os.Stat(ev.Name); err != nil || !info.IsDir() {
+		return
+	}
+	switch filepath.Dir(ev.Name) {
+	case agentsDir:
+		// A new agent directory; its sessions/ may already exist.
+		w.Add(ev.Name)
+		t.scanSessions(w, filepath.Join(ev.Name, "sessions"), hub)
+	default:
+		if filepath.Base(ev.Name) == "sessions" && filepath.Dir(filepath.Dir(ev.Name)) == agentsDir {
+			t.scanSessions(w, ev.Name, hub)
+		}
+	}
+}
+
+// tail parses the lines appended to a transcript and broadcasts them.
+func (t *transcriptTail) tail(path string, hub broadcaster) {
+	ca := config.GetAgentByID(agentIDForDir(filepath.Base(filepath.Dir(filepath.Dir(path)))))
+	if ca == nil {
+		return
+	}
+	lines, next, _, err := readAppended(path, t.offsets[path])
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[stream] read %s: %v", filepath.Base(path), err)
+		}
+		return
+	}
+	t.offsets[path] = next
+
+	agent := agentFromConfig(*ca)
+	var entries []OCStreamEntry
+	for _, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, parseJSONLToStream(entry, agent)...)
+	}
+	if len(entries) == 0 {
+		return
+	}
+	t.add(ca.ID, entries)
+	topics := agentTopics(ca.ID)
+	for _, e := range entries {
+		hub.Broadcast("stream_entry", e, topics...)
+	}
+}
+
+// add appends entries to an agent's ring.
+func (t *transcriptTail) add(agentID string, entries []OCStreamEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ring := append(t.recent[agentID], entries...)
+	if len(ring) > streamRecentPerAgent {
+		ring = append([]OCStreamEntry(nil), ring[len(ring)-streamRecentPerAgent:]...)
+	}
+	t.recent[agentID] = ring
+}
+
+// latest returns the newest limit entries across the agents matching
+// agentFilter, newest first. ok is false until the watcher is running.
+func (t *transcriptTail) latest(limit int, agentFilter string) (entries []OCStreamEntry, ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if !t.running {
+		return nil, false
+	}
+
+	entries = make([]OCStreamEntry, 0)
+	for _, ca := range config.GetAgents() {
+		if agentFilter != "" && !strings.EqualFold(ca.ID, agentFilter) && !strings.EqualFold(ca.Name, agentFilter) {
+			continue
+		}
+		entries = append(entries, t.recent[ca.ID]...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	for i := range entries {
+		entries[i].TimeStr = formatRelTime(entries[i].Timestamp)
+	}
+	return entries, true
+}
+
+// endOffset returns the offset just past the last complete line of path, so
+// tailing starts after the content already there. A line still being
+// written is left to be read once it's finished.
+func endOffset(path string) (fileOffset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileOffset{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fileOffset{}, err
+	}
+
+	o := fileOffset{Offset: info.Size(), Size: info.Size(), ModTime: info.ModTime().Truncate(time.Microsecond)}
+	n := info.Size()
+	if n > 64*1024 {
+		n = 64 * 1024
+	}
+	if n == 0 {
+		return o, nil
+	}
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, info.Size()-n); err != nil {
+		return o, err
+	}
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		o.Offset = info.Size() - n + int64(i) + 1
+	} else if n == info.Size() {
+		o.Offset = 0
+	}
+	return o, nil
+}
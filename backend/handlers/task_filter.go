@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/config"
+	"github.com/alghanim/agentboard/backend/models"
+
+	"github.com/lib/pq"
+)
+
+// taskFilter selects tasks. GetTasks builds one from its query parameters;
+// saved views store one as their definition. Zero fields don't filter, and
+// list fields match any of their values, except LabelsAll, which needs every
+// one. Labels match exactly, as spelled in the label catalog.
+type taskFilter struct {
+	Status        []string   `json:"status,omitempty"`
+	Assignee      []string   `json:"assignee,omitempty"`
+	Priority      []string   `json:"priority,omitempty"`
+	Team          []string   `json:"team,omitempty"`
+	Labels        []string   `json:"labels,omitempty"`
+	LabelsAll     []string   `json:"labels_all,omitempty"`
+	Stuck         *bool      `json:"stuck,omitempty"`
+	Overdue       *bool      `json:"overdue,omitempty"`
+	Subtree       string     `json:"subtree,omitempty"` // agent ID or name, "me" or "my_team"
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+}
+
+// Special subtree roots, resolved against the viewer.
+const (
+	subtreeMe     = "me"      // the viewer
+	subtreeMyTeam = "my_team" // the lead of the viewer's team
+)
+
+// taskFilterFromQuery reads GetTasks' query parameters. Dates that don't
+// parse are ignored, as they always have been.
+func taskFilterFromQuery(q url.Values) taskFilter {
+	var f taskFilter
+	for _, p := range []struct {
+		param string
+		dst   *[]string
+	}{{"status", &f.Status}, {"assignee", &f.Assignee}, {"priority", &f.Priority}, {"team", &f.Team}} {
+		if v := q.Get(p.param); v != "" {
+			*p.dst = []string{v}
+		}
+	}
+	// label is the original name for labels_any.
+	for _, p := range []struct {
+		param string
+		dst   *[]string
+	}{{"label", &f.Labels}, {"labels_any", &f.Labels}, {"labels_all", &f.LabelsAll}} {
+		for _, v := range q[p.param] {
+			*p.dst = append(*p.dst, splitList(v)...)
+		}
+	}
+	for _, p := range []struct {
+		param string
+		dst   **bool
+	}{{"stuck", &f.Stuck}, {"overdue", &f.Overdue}} {
+		if v, err := strconv.ParseBool(q.Get(p.param)); err == nil {
+			*p.dst = &v
+		}
+	}
+	f.Subtree = q.Get("subtree")
+	if t, err := time.Parse(time.RFC3339, q.Get("start_date")); err == nil {
+		f.CreatedAfter = &t
+	}
+	if t, err := time.Parse(time.RFC3339, q.Get("end_date")); err == nil {
+		f.CreatedBefore = &t
+	}
+	return f
+}
+
+// splitList splits a comma-separated parameter, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// validate checks what can be checked without a viewer.
+func (f taskFilter) validate() error {
+	switch f.Subtree {
+	case "", subtreeMe, subtreeMyTeam:
+		return nil
+	}
+	if resolveAgent(f.Subtree) == nil {
+		return fmt.Errorf("subtree: unknown agent %q", f.Subtree)
+	}
+	return nil
+}
+
+// conds returns the SQL conditions selecting the filter's tasks from a
+// tasks row aliased t, adding their parameters to args. viewer (an agent ID
+// or name) resolves the "me" and "my_team" subtrees.
+func (f taskFilter) conds(args *sqlArgs, viewer string) ([]string, error) {
+	var conds []string
+	for _, c := range []struct {
+		col    string
+		values []string
+	}{{"t.status", f.Status}, {"t.assignee", f.Assignee}, {"t.priority", f.Priority}, {"t.team", f.Team}} {
+		if len(c.values) > 0 {
+			conds = append(conds, c.col+" = ANY("+args.add(pq.Array(c.values))+"::text[])")
+		}
+	}
+	if len(f.Labels) > 0 {
+		conds = append(conds, "t.labels && "+args.add(pq.Array(f.Labels))+"::text[]")
+	}
+	if len(f.LabelsAll) > 0 {
+		conds = append(conds, "t.labels @> "+args.add(pq.Array(f.LabelsAll))+"::text[]")
+	}
+	if f.Stuck != nil {
+		cond := stuckCond(args)
+		if !*f.Stuck {
+			cond = "NOT (" + cond + ")"
+		}
+		conds = append(conds, cond)
+	}
+	if f.Overdue != nil {
+		cond := "(t.due_date IS NOT NULL AND t.due_date < NOW() AND t.status NOT IN " + doneStatusesSQL() + ")"
+		if !*f.Overdue {
+			cond = "NOT " + cond
+		}
+		conds = append(conds, cond)
+	}
+	if f.Subtree != "" {
+		root, err := subtreeRoot(f.Subtree, viewer)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, "t.assignee = ANY("+args.add(pq.Array(subtreeAssignees(root)))+"::text[])")
+	}
+	if f.CreatedAfter != nil {
+		conds = append(conds, "t.created_at >= "+args.add(*f.CreatedAfter))
+	}
+	if f.CreatedBefore != nil {
+		conds = append(conds, "t.created_at <= "+args.add(*f.CreatedBefore))
+	}
+	return conds, nil
+}
+
+// matches is conds for a task in memory. subtree holds the assignees of the
+// resolved subtree filter, if there is one.
+func (f taskFilter) matches(t models.Task, subtree map[string]bool) bool {
+	assignee := models.PtrToNullString(t.Assignee)
+	team := models.PtrToNullString(t.Team)
+	for _, c := range []struct {
+		value  sql.NullString
+		values []string
+	}{
+		{sql.NullString{String: t.Status, Valid: true}, f.Status},
+		{assignee, f.Assignee},
+		{sql.NullString{String: t.Priority, Valid: true}, f.Priority},
+		{team, f.Team},
+	} {
+		if len(c.values) > 0 && (!c.value.Valid || !containsString(c.values, c.value.String)) {
+			return false
+		}
+	}
+	if len(f.Labels) > 0 {
+		found := false
+		for _, l := range f.Labels {
+			if containsString(t.Labels, l) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, l := range f.LabelsAll {
+		if !containsString(t.Labels, l) {
+			return false
+		}
+	}
+	if f.Stuck != nil && isStuck(t) != *f.Stuck {
+		return false
+	}
+	if f.Overdue != nil {
+		overdue := t.DueDate != nil && t.DueDate.Before(time.Now()) && !containsString(config.DoneStatuses(), t.Status)
+		if overdue != *f.Overdue {
+			return false
+		}
+	}
+	if f.Subtree != "" && (!assignee.Valid || !subtree[assignee.String]) {
+		return false
+	}
+	if f.CreatedAfter != nil && t.CreatedAt.Before(*f.CreatedAfter) {
+		return false
+	}
+	if f.CreatedBefore != nil && t.CreatedAt.After(*f.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// stuckCond is isStuck in SQL: in a working status, and not updated within
+// its priority's stuck threshold.
+func stuckCond(args *sqlArgs) string {
+	esc := config.GetEscalation()
+	cutoff := "CASE LOWER(t.priority)"
+	for p, th := range esc.Stuck {
+		if p != "default" {
+			cutoff += " WHEN " + args.add(p) + " THEN " + args.add(th.Lead.Seconds())
+		}
+	}
+	cutoff += " ELSE " + args.add(esc.Stuck["default"].Lead.Seconds()) + " END"
+	return "(t.status IN " + workingStatusesSQL() + " AND t.updated_at < NOW() - make_interval(secs => " + cutoff + "))"
+}
+
+// subtreeRoot resolves a subtree filter to the agent at its top.
+func subtreeRoot(subtree, viewer string) (*config.Agent, error) {
+	switch subtree {
+	case subtreeMe, subtreeMyTeam:
+		me := resolveAgent(viewer)
+		if me == nil {
+			return nil, fmt.Errorf("subtree %q needs an agent caller", subtree)
+		}
+		if subtree == subtreeMyTeam && me.Team != "" {
+			if lead := config.GetTeamLead(me.Team); lead != nil {
+				return lead, nil
+			}
+		}
+		return me, nil
+	}
+	root := resolveAgent(subtree)
+	if root == nil {
+		return nil, fmt.Errorf("subtree: unknown agent %q", subtree)
+	}
+	return root, nil
+}
+
+// subtreeAssignees returns the IDs and names of root and every agent below
+// it in the hierarchy, as either may be stored as a task's assignee.
+func subtreeAssignees(root *config.Agent) []string {
+	out := []string{root.ID, root.Name}
+	for _, a := range config.GetAgents() {
+		if config.IsDescendant(a.ID, root.ID) {
+			out = append(out, a.ID, a.Name)
+		}
+	}
+	return out
+}
+
+// taskSortColumns are the fields a task list can be sorted by, as
+// expressions over tasks aliased t.
+var taskSortColumns = map[string]string{
+	"created_at": "t.created_at",
+	"updated_at": "t.updated_at",
+	"due_date":   "t.due_date",
+	"priority":   priorityRankSQL, // most urgent first
+	"status":     "t.status",
+	"title":      "t.title",
+}
+
+// taskOrderBy turns a sort spec such as "-priority,due_date" (a leading "-"
+// for descending) into an ORDER BY list. Ties fall back to newest first.
+func taskOrderBy(sort string) (string, error) {
+	var parts []string
+	for _, field := range splitList(sort) {
+		dir := " ASC"
+		if strings.HasPrefix(field, "-") {
+			field, dir = field[1:], " DESC"
+		}
+		col, ok := taskSortColumns[field]
+		if !ok {
+			return "", fmt.Errorf("sort: unknown field %q", field)
+		}
+		parts = append(parts, col+dir+" NULLS LAST")
+	}
+	parts = append(parts, "t.created_at DESC", "t.id")
+	return strings.Join(parts, ", "), nil
+}
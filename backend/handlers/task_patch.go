@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/db"
+	"github.com/alghanim/agentboard/backend/models"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// PatchTask handles PATCH /api/tasks/:id
+// Accepts a JSON Merge Patch (RFC 7396): only the fields present are changed,
+// and null clears a field. Status is excluded — use /transition for that.
+func (h *TaskHandler) PatchTask(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, _ := mime.ParseMediaType(ct)
+		if mt != "application/merge-patch+json" && mt != "application/json" {
+			respondError(w, http.StatusUnsupportedMediaType, "PATCH expects application/merge-patch+json")
+			return
+		}
+	}
+
+	expected, err := ifMatchVersion(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		respondError(w, http.StatusBadRequest, "merge patch must be a JSON object: "+err.Error())
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := scanTask(tx.QueryRow(`SELECT `+taskColumns+` FROM tasks WHERE id = $1 FOR UPDATE`, id))
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if expected.Valid && int64(before.Version) != expected.Int64 {
+		tx.Rollback()
+		respondTaskConflict(w, id)
+		return
+	}
+
+	after := before
+	if err := applyTaskMergePatch(&after, patch); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !allowTaskChanges(w, r, before, after) || refuseReassignOverBudget(w, before, after) {
+		return
+	}
+
+	if wf := workflowFor(after.Team, after.Assignee); !wf.HasStatus(after.Status) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Status %q is not part of the %s workflow", after.Status, wf.Name))
+		return
+	}
+
+	changes := diffTaskFields(before, after)
+	if len(changes) == 0 {
+		w.Header().Set("ETag", taskETag(before.Version))
+		respondJSON(w, http.StatusOK, before)
+		return
+	}
+
+	// A lease only survives reassignment to its own holder.
+	if _, err := tx.Exec(
+		`UPDATE tasks SET title=$1, description=$2, priority=$3, assignee=$4, team=$5,
+		 due_date=$6, parent_task_id=$7, labels=$8,
+		 lease_owner = CASE WHEN lease_owner = $4 THEN lease_owner END,
+		 lease_expires_at = CASE WHEN lease_owner = $4 THEN lease_expires_at END
+		 WHERE id=$9`,
+		after.Title, models.PtrToNullString(after.Description), after.Priority,
+		models.PtrToNullString(after.Assignee), models.PtrToNullString(after.Team),
+		models.PtrToNullTime(after.DueDate), models.PtrToNullString(after.ParentTaskID),
+		pq.Array(after.Labels), id,
+	); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	actor := getAgentFromContext(r)
+	if err := recordFieldChanges(tx, id, changes, actor); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	updated, err := loadTask(id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logActivity(actor, "task_updated", id, map[string]string{"fields": changedFieldNames(changes)})
+	h.Hub.Broadcast("task_updated", updated, append(taskEventTopics(updated),
+		taskTopics(id, before.Assignee, before.Team)...)...)
+	h.announceLeaseRelease(before, updated, actor)
+
+	w.Header().Set("ETag", taskETag(updated.Version))
+	respondJSON(w, http.StatusOK, updated)
+}
+
+// applyTaskMergePatch applies the members of a merge patch to task.
+func applyTaskMergePatch(task *models.Task, patch map[string]json.RawMessage) error {
+	for field, raw := range patch {
+		isNull := bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+		switch field {
+		case "title":
+			var v string
+			if isNull || json.Unmarshal(raw, &v) != nil || strings.TrimSpace(v) == "" {
+				return fmt.Errorf("title must be a non-empty string")
+			}
+			task.Title = v
+		case "priority":
+			var v string
+			if isNull || json.Unmarshal(raw, &v) != nil {
+				return fmt.Errorf("priority must be a string")
+			}
+			task.Priority = v
+		case "description", "assignee", "team", "parent_task_id":
+			var v *string
+			if !isNull {
+				var s string
+				if err := json.Unmarshal(raw, &s); err != nil {
+					return fmt.Errorf("%s must be a string or null", field)
+				}
+				v = &s
+			}
+			switch field {
+			case "description":
+				task.Description = v
+			case "assignee":
+				task.Assignee = v
+			case "team":
+				task.Team = v
+			case "parent_task_id":
+				task.ParentTaskID = v
+			}
+		case "due_date":
+			if isNull {
+				task.DueDate = nil
+				continue
+			}
+			var t time.Time
+			if err := json.Unmarshal(raw, &t); err != nil {
+				return fmt.Errorf("due_date must be an RFC 3339 timestamp or null")
+			}
+			task.DueDate = &t
+		case "labels":
+			// Merge Patch replaces arrays wholesale.
+			if isNull {
+				task.Labels = nil
+				continue
+			}
+			var v []string
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return fmt.Errorf("labels must be an array of strings or null")
+			}
+			task.Labels = v
+		case "status":
+			return fmt.Errorf("status cannot be patched; use POST /api/tasks/{id}/transition")
+		case "id", "created_at", "updated_at", "completed_at", "version", "stuck":
+			return fmt.Errorf("%s is read-only", field)
+		default:
+			return fmt.Errorf("unknown task field %q", field)
+		}
+	}
+	return nil
+}
+
+// fieldChange is one changed task field, with JSON-encoded old and new values.
+type fieldChange struct {
+	Field    string
+	OldValue []byte
+	NewValue []byte
+}
+
+// diffTaskFields lists the audited fields that differ between two versions of
+// a task. Status is not included; it's tracked in task_history.
+func diffTaskFields(before, after models.Task) []fieldChange {
+	pairs := []struct {
+		field    string
+		old, new interface{}
+	}{
+		{"title", before.Title, after.Title},
+		{"description", before.Description, after.Description},
+		{"priority", before.Priority, after.Priority},
+		{"assignee", before.Assignee, after.Assignee},
+		{"team", before.Team, after.Team},
+		{"labels", []string(before.Labels), []string(after.Labels)},
+		{"due_date", utcTime(before.DueDate), utcTime(after.DueDate)},
+		{"parent_task_id", before.ParentTaskID, after.ParentTaskID},
+	}
+
+	var changes []fieldChange
+	for _, p := range pairs {
+		o, _ := json.Marshal(p.old)
+		n, _ := json.Marshal(p.new)
+		if p.field == "labels" {
+			// nil and [] are the same label set.
+			if string(o) == "[]" {
+				o = []byte("null")
+			}
+			if string(n) == "[]" {
+				n = []byte("null")
+			}
+		}
+		if !bytes.Equal(o, n) {
+			changes = append(changes, fieldChange{Field: p.field, OldValue: o, NewValue: n})
+		}
+	}
+	return changes
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordFieldChanges appends field changes to task_field_history.
+func recordFieldChanges(ex execer, taskID string, changes []fieldChange, changedBy string) error {
+	for _, c := range changes {
+		if _, err := ex.Exec(`
+			INSERT INTO task_field_history (task_id, field, old_value, new_value, changed_by)
+			VALUES ($1, $2, $3, $4, $5)`,
+			taskID, c.Field, string(c.OldValue), string(c.NewValue), changedBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func changedFieldNames(changes []fieldChange) string {
+	names := make([]string, len(changes))
+	for i, c := range changes {
+		names[i] = c.Field
+	}
+	return strings.Join(names, ",")
+}
+
+// utcTime normalises a timestamp so equal instants compare equal as JSON.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
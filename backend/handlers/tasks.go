@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"crypto/sha1"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/alghanim/agentboard/backend/auth"
+	"github.com/alghanim/agentboard/backend/config"
 	"github.com/alghanim/agentboard/backend/db"
 	"github.com/alghanim/agentboard/backend/models"
 	"github.com/alghanim/agentboard/backend/websocket"
@@ -22,46 +26,18 @@ type TaskHandler struct {
 
 // GetTasks handles GET /api/tasks
 func (h *TaskHandler) GetTasks(w http.ResponseWriter, r *http.Request) {
-	query := `SELECT id, title, description, status, priority, assignee, team,
-	          due_date, created_at, updated_at, completed_at, parent_task_id, labels
-	          FROM tasks WHERE 1=1`
-	args := []interface{}{}
-	argCount := 1
-
-	if status := r.URL.Query().Get("status"); status != "" {
-		query += fmt.Sprintf(" AND status = $%d", argCount)
-		args = append(args, status)
-		argCount++
-	}
-	if assignee := r.URL.Query().Get("assignee"); assignee != "" {
-		query += fmt.Sprintf(" AND assignee = $%d", argCount)
-		args = append(args, assignee)
-		argCount++
-	}
-	if priority := r.URL.Query().Get("priority"); priority != "" {
-		query += fmt.Sprintf(" AND priority = $%d", argCount)
-		args = append(args, priority)
-		argCount++
-	}
-	if team := r.URL.Query().Get("team"); team != "" {
-		query += fmt.Sprintf(" AND team = $%d", argCount)
-		args = append(args, team)
-		argCount++
-	}
-	if startDate := r.URL.Query().Get("start_date"); startDate != "" {
-		if t, err := time.Parse(time.RFC3339, startDate); err == nil {
-			query += fmt.Sprintf(" AND created_at >= $%d", argCount)
-			args = append(args, t)
-			argCount++
-		}
+	var args sqlArgs
+	conds, err := taskFilterFromQuery(r.URL.Query()).conds(&args, getAgentFromContext(r))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
-	if endDate := r.URL.Query().Get("end_date"); endDate != "" {
-		if t, err := time.Parse(time.RFC3339, endDate); err == nil {
-			query += fmt.Sprintf(" AND created_at <= $%d", argCount)
-			args = append(args, t)
-			argCount++
-		}
+	orderBy, err := taskOrderBy(r.URL.Query().Get("sort"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
+	query := `SELECT ` + taskColumns + ` FROM tasks t WHERE ` + strings.Join(append([]string{"TRUE"}, conds...), " AND ")
 
 	// Pagination — default 100, max 500
 	limit := 100
@@ -79,8 +55,7 @@ func (h *TaskHandler) GetTasks(w http.ResponseWriter, r *http.Request) {
 			offset = v
 		}
 	}
-	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", argCount, argCount+1)
-	args = append(args, limit, offset)
+	query += " ORDER BY " + orderBy + " LIMIT " + args.add(limit) + " OFFSET " + args.add(offset)
 
 	rows, err := db.DB.Query(query, args...)
 	if err != nil {
@@ -91,26 +66,11 @@ func (h *TaskHandler) GetTasks(w http.ResponseWriter, r *http.Request) {
 
 	tasks := []models.Task{}
 	for rows.Next() {
-		var task models.Task
-		var desc, assignee, team, parentID sql.NullString
-		var dueDate, completedAt sql.NullTime
-
-		if err := rows.Scan(&task.ID, &task.Title, &desc, &task.Status,
-			&task.Priority, &assignee, &team, &dueDate,
-			&task.CreatedAt, &task.UpdatedAt, &completedAt,
-			&parentID, &task.Labels); err != nil {
+		task, err := scanTask(rows)
+		if err != nil {
 			respondError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
-
-		task.Description = models.NullStringToPtr(desc)
-		task.Assignee = models.NullStringToPtr(assignee)
-		task.Team = models.NullStringToPtr(team)
-		task.ParentTaskID = models.NullStringToPtr(parentID)
-		task.DueDate = models.NullTimeToPtr(dueDate)
-		task.CompletedAt = models.NullTimeToPtr(completedAt)
-		task.Stuck = isStuck(task)
-
 		tasks = append(tasks, task)
 	}
 	if err := rows.Err(); err != nil {
@@ -118,26 +78,21 @@ func (h *TaskHandler) GetTasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	etag := taskListETag(tasks)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	respondJSON(w, http.StatusOK, tasks)
 }
 
 // GetTask handles GET /api/tasks/:id
+// The task version is returned as the ETag; If-None-Match yields 304.
 func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	var task models.Task
-	var desc, assignee, team, parentID sql.NullString
-	var dueDate, completedAt sql.NullTime
-
-	err := db.DB.QueryRow(
-		`SELECT id, title, description, status, priority, assignee, team,
-		 due_date, created_at, updated_at, completed_at, parent_task_id, labels
-		 FROM tasks WHERE id = $1`, id,
-	).Scan(&task.ID, &task.Title, &desc, &task.Status,
-		&task.Priority, &assignee, &team, &dueDate,
-		&task.CreatedAt, &task.UpdatedAt, &completedAt,
-		&parentID, &task.Labels)
-
+	task, err := loadTask(id)
 	if err == sql.ErrNoRows {
 		respondError(w, http.StatusNotFound, "Task not found")
 		return
@@ -147,14 +102,12 @@ func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task.Description = models.NullStringToPtr(desc)
-	task.Assignee = models.NullStringToPtr(assignee)
-	task.Team = models.NullStringToPtr(team)
-	task.ParentTaskID = models.NullStringToPtr(parentID)
-	task.DueDate = models.NullTimeToPtr(dueDate)
-	task.CompletedAt = models.NullTimeToPtr(completedAt)
-	task.Stuck = isStuck(task)
-
+	etag := taskETag(task.Version)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	respondJSON(w, http.StatusOK, task)
 }
 
@@ -167,8 +120,13 @@ func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	wf := workflowFor(task.Team, task.Assignee)
 	if task.Status == "" {
-		task.Status = "todo"
+		task.Status = wf.Initial
+	}
+	if !wf.HasStatus(task.Status) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Status %q is not part of the %s workflow", task.Status, wf.Name))
+		return
 	}
 	if task.Priority == "" {
 		task.Priority = "medium"
@@ -177,12 +135,12 @@ func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 	err := db.DB.QueryRow(
 		`INSERT INTO tasks (title, description, status, priority, assignee, team, due_date, parent_task_id, labels)
 		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		 RETURNING id, created_at, updated_at`,
+		 RETURNING id, created_at, updated_at, version`,
 		task.Title, models.PtrToNullString(task.Description), task.Status, task.Priority,
 		models.PtrToNullString(task.Assignee), models.PtrToNullString(task.Team),
 		models.PtrToNullTime(task.DueDate), models.PtrToNullString(task.ParentTaskID),
 		pq.Array(task.Labels),
-	).Scan(&task.ID, &task.CreatedAt, &task.UpdatedAt)
+	).Scan(&task.ID, &task.CreatedAt, &task.UpdatedAt, &task.Version)
 
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
@@ -190,15 +148,23 @@ func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logActivity(getAgentFromContext(r), "task_created", task.ID, map[string]string{"title": task.Title})
-	h.Hub.Broadcast("task_created", task)
+	h.Hub.Broadcast("task_created", task, taskEventTopics(task)...)
 
+	w.Header().Set("ETag", taskETag(task.Version))
 	respondJSON(w, http.StatusCreated, task)
 }
 
 // UpdateTask handles PUT /api/tasks/:id
+// Honors If-Match: a stale version gets 409 with the current row.
 func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
+	expected, err := ifMatchVersion(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
 	var task models.Task
 	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
@@ -207,68 +173,182 @@ func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	}
 	task.ID = id
 
-	result, err := db.DB.Exec(
+	wf := workflowFor(task.Team, task.Assignee)
+	if !wf.HasStatus(task.Status) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Status %q is not part of the %s workflow", task.Status, wf.Name))
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := scanTask(tx.QueryRow(`SELECT `+taskColumns+` FROM tasks WHERE id = $1 FOR UPDATE`, id))
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if expected.Valid && int64(before.Version) != expected.Int64 {
+		tx.Rollback()
+		respondTaskConflict(w, id)
+		return
+	}
+	if !allowTaskChanges(w, r, before, task) || refuseReassignOverBudget(w, before, task) {
+		return
+	}
+	if !wf.CanTransition(before.Status, task.Status) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid status transition %s → %s in the %s workflow", before.Status, task.Status, wf.Name))
+		return
+	}
+	if task.Status == wf.Working() && before.Status != task.Status && refuseBlocked(w, id) {
+		return
+	}
+
+	if _, err := tx.Exec(
 		`UPDATE tasks SET title=$1, description=$2, status=$3, priority=$4,
-		 assignee=$5, team=$6, due_date=$7, parent_task_id=$8, labels=$9
+		 assignee=$5, team=$6, due_date=$7, parent_task_id=$8, labels=$9,
+		 completed_at = CASE WHEN $3 = $11 THEN NOW() ELSE completed_at END,
+		 lease_owner = CASE WHEN $3 = $12 AND lease_owner = $5 THEN lease_owner END,
+		 lease_expires_at = CASE WHEN $3 = $12 AND lease_owner = $5 THEN lease_expires_at END
 		 WHERE id=$10`,
 		task.Title, models.PtrToNullString(task.Description), task.Status, task.Priority,
 		models.PtrToNullString(task.Assignee), models.PtrToNullString(task.Team),
 		models.PtrToNullTime(task.DueDate), models.PtrToNullString(task.ParentTaskID),
-		pq.Array(task.Labels), id,
-	)
-	if err != nil {
+		pq.Array(task.Labels), id, wf.Done, wf.Working(),
+	); err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	if n, _ := result.RowsAffected(); n == 0 {
-		respondError(w, http.StatusNotFound, "Task not found")
+
+	actor := getAgentFromContext(r)
+	if err := recordFieldChanges(tx, id, diffTaskFields(before, task), actor); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if before.Status != task.Status {
+		recordTransition(id, before.Status, task.Status, actor, "")
+	}
 
-	if task.Status == "done" {
-		db.DB.Exec(`UPDATE tasks SET completed_at = NOW() WHERE id = $1`, id)
+	updated, err := loadTask(id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
-	logActivity(getAgentFromContext(r), "task_updated", id, map[string]string{"status": task.Status})
-	h.Hub.Broadcast("task_updated", task)
+	logActivity(actor, "task_updated", id, map[string]string{"status": updated.Status})
+	// Subscribers of the old assignee and team hear about it too.
+	h.Hub.Broadcast("task_updated", updated, append(taskEventTopics(updated),
+		taskTopics(id, before.Assignee, before.Team)...)...)
+	h.announceLeaseRelease(before, updated, actor)
 
-	respondJSON(w, http.StatusOK, task)
+	if updated.Status == wf.Done && before.Status != wf.Done {
+		h.releaseDependents(id, actor)
+	}
+
+	w.Header().Set("ETag", taskETag(updated.Version))
+	respondJSON(w, http.StatusOK, updated)
 }
 
 // DeleteTask handles DELETE /api/tasks/:id
 func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
-
-	result, err := db.DB.Exec(`DELETE FROM tasks WHERE id = $1`, id)
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+	if !allowOnTask(w, r, "task.delete", id) {
 		return
 	}
-	if n, _ := result.RowsAffected(); n == 0 {
+
+	var assignee, team sql.NullString
+	err := db.DB.QueryRow(`DELETE FROM tasks WHERE id = $1 RETURNING assignee, team`, id).Scan(&assignee, &team)
+	if err == sql.ErrNoRows {
 		respondError(w, http.StatusNotFound, "Task not found")
 		return
 	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
 	logActivity(getAgentFromContext(r), "task_deleted", id, nil)
-	h.Hub.Broadcast("task_deleted", map[string]string{"id": id})
+	h.Hub.Broadcast("task_deleted", map[string]string{"id": id},
+		append(taskTopics(id, models.NullStringToPtr(assignee), models.NullStringToPtr(team)), savedViews.forget(id)...)...)
 
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Task deleted"})
 }
 
 // AssignTask handles POST /api/tasks/:id/assign
+// Body: {"assignee": "<agent>"} or {"auto": true} (equivalently
+// {"assignee": "auto"}) to let the routing rules pick an agent.
 func (h *TaskHandler) AssignTask(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
 	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
 	var data struct {
 		Assignee string `json:"assignee"`
+		Auto     bool   `json:"auto"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
 		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if _, err := db.DB.Exec(`UPDATE tasks SET assignee = $1 WHERE id = $2`, data.Assignee, id); err != nil {
+	details := map[string]string{"assignee": data.Assignee}
+	var decision *assignDecision
+	if !data.Auto && data.Assignee != "auto" && data.Assignee != "" && refuseOverBudget(w, data.Assignee) {
+		return
+	}
+	if data.Auto || data.Assignee == "auto" {
+		task, err := loadTask(id)
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Task not found")
+			return
+		}
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		decision, err = autoAssign(task)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if decision.Assignee == "" {
+			respondJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":    "No eligible agent",
+				"decision": decision,
+			})
+			return
+		}
+		data.Assignee = decision.Assignee
+		details = map[string]string{
+			"assignee":    decision.Assignee,
+			"mode":        "auto",
+			"rule":        decision.Rule,
+			"explanation": decision.Explanation,
+		}
+	}
+
+	if !allowAssign(w, r, id, data.Assignee) {
+		return
+	}
+
+	previous := taskTopicsByID(id)
+
+	// A lease only survives reassignment to its own holder.
+	if _, err := db.DB.Exec(`
+		UPDATE tasks SET assignee = $1,
+		       lease_owner = CASE WHEN lease_owner = $1 THEN lease_owner END,
+		       lease_expires_at = CASE WHEN lease_owner = $1 THEN lease_expires_at END
+		WHERE id = $2`, data.Assignee, id); err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -276,9 +356,14 @@ func (h *TaskHandler) AssignTask(w http.ResponseWriter, r *http.Request) {
 	// Update agent's current task if they exist in DB
 	db.DB.Exec(`UPDATE agents SET current_task_id = $1::uuid WHERE id = $2`, id, data.Assignee)
 
-	logActivity(getAgentFromContext(r), "task_assigned", id, map[string]string{"assignee": data.Assignee})
-	h.Hub.Broadcast("task_assigned", map[string]string{"task_id": id, "assignee": data.Assignee})
+	logActivity(getAgentFromContext(r), "task_assigned", id, details)
+	h.Hub.Broadcast("task_assigned", map[string]string{"task_id": id, "assignee": data.Assignee},
+		append(taskTopicsByID(id), previous...)...)
 
+	if decision != nil {
+		respondJSON(w, http.StatusOK, map[string]interface{}{"message": "Task assigned", "decision": decision})
+		return
+	}
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Task assigned"})
 }
 
@@ -286,6 +371,12 @@ func (h *TaskHandler) AssignTask(w http.ResponseWriter, r *http.Request) {
 func (h *TaskHandler) TransitionTask(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
+	expected, err := ifMatchVersion(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
 	var data struct {
 		Status string `json:"status"`
@@ -295,72 +386,86 @@ func (h *TaskHandler) TransitionTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	validTransitions := map[string][]string{
-		"todo":     {"progress", "backlog"},
-		"backlog":  {"todo", "next"},
-		"next":     {"progress"},
-		"progress": {"review", "blocked", "todo"},
-		"review":   {"done", "progress"},
-		"blocked":  {"todo", "progress"},
-		"done":     {},
-	}
-
 	var currentStatus string
-	if err := db.DB.QueryRow(`SELECT status FROM tasks WHERE id = $1`, id).Scan(&currentStatus); err != nil {
+	var currentVersion int
+	var team, assignee sql.NullString
+	if err := db.DB.QueryRow(`SELECT status, team, assignee, version FROM tasks WHERE id = $1`, id).
+		Scan(&currentStatus, &team, &assignee, &currentVersion); err != nil {
 		respondError(w, http.StatusNotFound, "Task not found")
 		return
 	}
+	if expected.Valid && int64(currentVersion) != expected.Int64 {
+		respondTaskConflict(w, id)
+		return
+	}
+	if !allow(w, r, "task.transition", newPolicyTarget(id, assignee.String, team.String)) {
+		return
+	}
 
-	valid := false
-	for _, s := range validTransitions[currentStatus] {
-		if s == data.Status {
-			valid = true
-			break
-		}
+	wf := workflowFor(models.NullStringToPtr(team), models.NullStringToPtr(assignee))
+	if !wf.HasStatus(data.Status) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Status %q is not part of the %s workflow", data.Status, wf.Name))
+		return
 	}
-	if !valid && data.Status != currentStatus {
-		respondError(w, http.StatusBadRequest, "Invalid status transition")
+	if !wf.CanTransition(currentStatus, data.Status) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid status transition %s → %s in the %s workflow", currentStatus, data.Status, wf.Name))
 		return
 	}
 
-	if _, err := db.DB.Exec(`UPDATE tasks SET status = $1 WHERE id = $2`, data.Status, id); err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+	if data.Status == wf.Working() && currentStatus != data.Status && refuseBlocked(w, id) {
 		return
 	}
 
-	if data.Status == "done" {
-		db.DB.Exec(`UPDATE tasks SET completed_at = NOW() WHERE id = $1`, id)
+	// Guard on the version we validated against so a concurrent change
+	// between the read above and this write is reported, not overwritten.
+	updated, err := scanTask(db.DB.QueryRow(
+		`UPDATE tasks SET status = $1,
+		 completed_at = CASE WHEN $1 = $4 THEN NOW() ELSE completed_at END,
+		 lease_owner = CASE WHEN $1 = $5 THEN lease_owner END,
+		 lease_expires_at = CASE WHEN $1 = $5 THEN lease_expires_at END
+		 WHERE id = $2 AND version = $3
+		 RETURNING `+taskColumns, data.Status, id, currentVersion, wf.Done, wf.Working()))
+	if err == sql.ErrNoRows {
+		respondTaskConflict(w, id)
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	// Record status transition in task_history
 	changedBy := getAgentFromContext(r)
-	_, _ = db.DB.Exec(`
-		INSERT INTO task_history (task_id, from_status, to_status, changed_by, changed_at)
-		VALUES ($1, $2, $3, $4, NOW())`,
-		id, currentStatus, data.Status, changedBy)
+	recordTransition(id, currentStatus, data.Status, changedBy, "")
 
 	logActivity(changedBy, "task_transitioned", id, map[string]string{
 		"from": currentStatus, "to": data.Status,
 	})
-	h.Hub.Broadcast("task_transitioned", map[string]string{"task_id": id, "status": data.Status})
+	h.Hub.Broadcast("task_transitioned", map[string]string{"task_id": id, "status": data.Status},
+		taskEventTopics(updated)...)
+
+	if data.Status == wf.Done && currentStatus != wf.Done {
+		h.releaseDependents(id, changedBy)
+	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"message": "Task status updated"})
+	w.Header().Set("ETag", taskETag(updated.Version))
+	respondJSON(w, http.StatusOK, map[string]interface{}{"message": "Task status updated", "version": updated.Version})
 }
 
-// isStuck returns true if the task has been in-progress (status="progress")
-// for more than 2 hours without an update.
+// isStuck returns true if the task has been in a working status longer than
+// its priority's stuck threshold without an update.
 func isStuck(task models.Task) bool {
-	return task.Status == "progress" && task.UpdatedAt.Before(time.Now().Add(-2*time.Hour))
+	th := config.GetEscalation().StuckThreshold(task.Priority)
+	return containsString(config.WorkingStatuses(), task.Status) && time.Since(task.UpdatedAt) > th.Lead
 }
 
 // GetStuckTasks handles GET /api/tasks/stuck
+// Thresholds come from the escalation section of agents.yaml.
 func (h *TaskHandler) GetStuckTasks(w http.ResponseWriter, r *http.Request) {
 	rows, err := db.DB.Query(`
-		SELECT id, title, description, status, priority, assignee, team,
-		       due_date, created_at, updated_at, completed_at, parent_task_id, labels
+		SELECT ` + taskColumns + `
 		FROM tasks
-		WHERE status = 'progress'
-		  AND updated_at < NOW() - INTERVAL '2 hours'
+		WHERE status IN ` + workingStatusesSQL() + `
 		ORDER BY updated_at ASC
 	`)
 	if err != nil {
@@ -371,27 +476,14 @@ func (h *TaskHandler) GetStuckTasks(w http.ResponseWriter, r *http.Request) {
 
 	tasks := []models.Task{}
 	for rows.Next() {
-		var task models.Task
-		var desc, assignee, team, parentID sql.NullString
-		var dueDate, completedAt sql.NullTime
-
-		if err := rows.Scan(&task.ID, &task.Title, &desc, &task.Status,
-			&task.Priority, &assignee, &team, &dueDate,
-			&task.CreatedAt, &task.UpdatedAt, &completedAt,
-			&parentID, &task.Labels); err != nil {
+		task, err := scanTask(rows)
+		if err != nil {
 			respondError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
-
-		task.Description = models.NullStringToPtr(desc)
-		task.Assignee = models.NullStringToPtr(assignee)
-		task.Team = models.NullStringToPtr(team)
-		task.ParentTaskID = models.NullStringToPtr(parentID)
-		task.DueDate = models.NullTimeToPtr(dueDate)
-		task.CompletedAt = models.NullTimeToPtr(completedAt)
-		task.Stuck = true // all results from this query are stuck by definition
-
-		tasks = append(tasks, task)
+		if task.Stuck {
+			tasks = append(tasks, task)
+		}
 	}
 	if err := rows.Err(); err != nil {
 		respondError(w, http.StatusInternalServerError, "row iteration error: "+err.Error())
@@ -402,14 +494,23 @@ func (h *TaskHandler) GetStuckTasks(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetTaskHistory handles GET /api/tasks/{id}/history
+// Returns the unified timeline: status transitions and field changes, oldest first.
 func (h *TaskHandler) GetTaskHistory(w http.ResponseWriter, r *http.Request) {
 	taskID := mux.Vars(r)["id"]
 
 	rows, err := db.DB.Query(`
-		SELECT id, task_id, from_status, to_status, changed_by, changed_at, note
+		SELECT 'status' AS kind, id, task_id, from_status, to_status,
+		       NULL AS field, NULL::jsonb AS old_value, NULL::jsonb AS new_value,
+		       changed_by, changed_at, note
 		FROM task_history
 		WHERE task_id = $1
-		ORDER BY changed_at ASC
+		UNION ALL
+		SELECT 'field', id, task_id, NULL, NULL,
+		       field, old_value, new_value,
+		       changed_by, changed_at, NULL
+		FROM task_field_history
+		WHERE task_id = $1
+		ORDER BY changed_at ASC, kind ASC, id ASC
 	`, taskID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
@@ -420,15 +521,23 @@ func (h *TaskHandler) GetTaskHistory(w http.ResponseWriter, r *http.Request) {
 	history := []models.TaskHistory{}
 	for rows.Next() {
 		var h models.TaskHistory
-		var fromStatus, changedBy, note sql.NullString
+		var fromStatus, toStatus, field, oldValue, newValue, changedBy, note sql.NullString
 
-		if err := rows.Scan(&h.ID, &h.TaskID, &fromStatus, &h.ToStatus,
-			&changedBy, &h.ChangedAt, &note); err != nil {
+		if err := rows.Scan(&h.Kind, &h.ID, &h.TaskID, &fromStatus, &toStatus,
+			&field, &oldValue, &newValue, &changedBy, &h.ChangedAt, &note); err != nil {
 			respondError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
 		h.FromStatus = models.NullStringToPtr(fromStatus)
+		h.ToStatus = models.NullStringToPtr(toStatus)
+		h.Field = models.NullStringToPtr(field)
+		if oldValue.Valid {
+			h.OldValue = json.RawMessage(oldValue.String)
+		}
+		if newValue.Valid {
+			h.NewValue = json.RawMessage(newValue.String)
+		}
 		h.ChangedBy = models.NullStringToPtr(changedBy)
 		h.Note = models.NullStringToPtr(note)
 
@@ -445,19 +554,22 @@ func (h *TaskHandler) GetTaskHistory(w http.ResponseWriter, r *http.Request) {
 // GetMyTasks handles GET /api/tasks/mine?agent_id=ID
 func (h *TaskHandler) GetMyTasks(w http.ResponseWriter, r *http.Request) {
 	agentID := r.URL.Query().Get("agent_id")
+	if id, ok := auth.FromRequest(r); ok && agentID == "" && id.Kind == auth.KindAgent {
+		agentID = id.AgentID
+	}
 	if agentID == "" {
 		respondError(w, http.StatusBadRequest, "agent_id query parameter is required")
 		return
 	}
+	if refuseOverBudget(w, agentID) {
+		return
+	}
 
 	rows, err := db.DB.Query(`
-		SELECT id, title, description, status, priority, assignee, team,
-		       due_date, created_at, updated_at, completed_at, parent_task_id, labels
+		SELECT `+taskColumns+`
 		FROM tasks
-		WHERE assignee = $1 AND status IN ('todo', 'progress')
-		ORDER BY CASE WHEN priority = 'critical' THEN 0 WHEN priority = 'urgent' THEN 1
-		              WHEN priority = 'high' THEN 2 WHEN priority = 'medium' THEN 3 ELSE 4 END,
-		         created_at DESC
+		WHERE assignee = $1 AND status IN `+activeStatusesSQL()+`
+		ORDER BY `+priorityRankSQL+`, created_at DESC
 	`, agentID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
@@ -467,10 +579,7 @@ func (h *TaskHandler) GetMyTasks(w http.ResponseWriter, r *http.Request) {
 
 	tasks := []models.Task{}
 	for rows.Next() {
-		var t models.Task
-		err := rows.Scan(&t.ID, &t.Title, &t.Description, &t.Status, &t.Priority,
-			&t.Assignee, &t.Team, &t.DueDate, &t.CreatedAt, &t.UpdatedAt,
-			&t.CompletedAt, &t.ParentTaskID, pq.Array(&t.Labels))
+		t, err := scanTask(rows)
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, err.Error())
 			return
@@ -483,3 +592,97 @@ func (h *TaskHandler) GetMyTasks(w http.ResponseWriter, r *http.Request) {
 	}
 	respondJSON(w, http.StatusOK, tasks)
 }
+
+// priorityRankSQL orders tasks most urgent first.
+const priorityRankSQL = `CASE WHEN priority = 'critical' THEN 0 WHEN priority = 'urgent' THEN 1
+	WHEN priority = 'high' THEN 2 WHEN priority = 'medium' THEN 3 ELSE 4 END`
+
+// taskColumns is the column list every task query selects, in scanTask order.
+const taskColumns = `id, title, description, status, priority, assignee, team,
+	due_date, created_at, updated_at, completed_at, parent_task_id, labels, version,
+	lease_owner, lease_expires_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTask reads one row selected with taskColumns.
+func scanTask(row rowScanner) (models.Task, error) {
+	var task models.Task
+	var desc, assignee, team, parentID, leaseOwner sql.NullString
+	var dueDate, completedAt, leaseExpires sql.NullTime
+
+	if err := row.Scan(&task.ID, &task.Title, &desc, &task.Status,
+		&task.Priority, &assignee, &team, &dueDate,
+		&task.CreatedAt, &task.UpdatedAt, &completedAt,
+		&parentID, &task.Labels, &task.Version,
+		&leaseOwner, &leaseExpires); err != nil {
+		return task, err
+	}
+
+	task.Description = models.NullStringToPtr(desc)
+	task.Assignee = models.NullStringToPtr(assignee)
+	task.Team = models.NullStringToPtr(team)
+	task.ParentTaskID = models.NullStringToPtr(parentID)
+	task.DueDate = models.NullTimeToPtr(dueDate)
+	task.CompletedAt = models.NullTimeToPtr(completedAt)
+	task.LeaseOwner = models.NullStringToPtr(leaseOwner)
+	task.LeaseExpires = models.NullTimeToPtr(leaseExpires)
+	task.Stuck = isStuck(task)
+	return task, nil
+}
+
+// loadTask fetches a single task; returns sql.ErrNoRows if it doesn't exist.
+func loadTask(id string) (models.Task, error) {
+	return scanTask(db.DB.QueryRow(`SELECT `+taskColumns+` FROM tasks WHERE id = $1`, id))
+}
+
+// taskETag formats a task version as a strong entity tag.
+func taskETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// taskListETag derives a weak entity tag for a list of tasks from their
+// IDs and versions, so any change to any listed task changes the tag.
+func taskListETag(tasks []models.Task) string {
+	h := sha1.New()
+	for _, t := range tasks {
+		fmt.Fprintf(h, "%s:%d;", t.ID, t.Version)
+	}
+	return fmt.Sprintf(`W/"%x"`, h.Sum(nil))
+}
+
+// ifMatchVersion parses the If-Match header into a task version.
+// An absent header or "*" yields an invalid (NULL) version, meaning
+// "don't check".
+func ifMatchVersion(r *http.Request) (sql.NullInt64, error) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" || v == "*" {
+		return sql.NullInt64{}, nil
+	}
+	v = strings.Trim(strings.TrimPrefix(v, "W/"), `"`)
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return sql.NullInt64{}, fmt.Errorf("invalid If-Match header: expected a task version ETag such as \"3\"")
+	}
+	return sql.NullInt64{Int64: n, Valid: true}, nil
+}
+
+// respondTaskConflict answers a failed conditional write: 404 if the task is
+// gone, otherwise 409 with the current row and its ETag.
+func respondTaskConflict(w http.ResponseWriter, id string) {
+	current, err := loadTask(id)
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Task not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("ETag", taskETag(current.Version))
+	respondJSON(w, http.StatusConflict, map[string]interface{}{
+		"error":   "Task was modified by someone else; reload and retry",
+		"current": current,
+	})
+}
@@ -1,27 +1,15 @@
 package handlers
 
 import (
-	"bufio"
 	"encoding/json"
 	"net/http"
-	"os"
-	"path/filepath"
 	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/alghanim/agentboard/backend/config"
 )
 
-// Model pricing per 1M tokens
-var modelPricing = map[string]struct{ In, Out float64 }{
-	"anthropic/claude-sonnet-4-6": {3.0, 15.0},
-	"anthropic/claude-opus-4-6":   {15.0, 75.0},
-	"google/gemini-2.5-pro":       {1.25, 10.0},
-	"google/gemini-2.5-flash":     {0.075, 0.30},
-}
-
 // tokenMessage represents a single assistant message with usage data from JSONL
 type tokenMessage struct {
 	Timestamp time.Time
@@ -32,161 +20,102 @@ type tokenMessage struct {
 	CacheRead int64
 	CacheWrite int64
 	TotalTokens int64
-	CostTotal float64
+	CostTotal float64 // as reported in the JSONL; 0 if the provider didn't report one
+	File      string // source JSONL path
 }
 
-// parseAllTokenData scans all agent JSONL session files and extracts token usage
-func parseAllTokenData() []tokenMessage {
-	openClawDir := config.GetOpenClawDir()
-	agentsDir := filepath.Join(openClawDir, "agents")
-
-	entries, err := os.ReadDir(agentsDir)
-	if err != nil {
-		return nil
+// Cost returns the reported cost, or prices the usage at the rates in force
+// when the message was sent.
+func (m tokenMessage) Cost() float64 {
+	if m.CostTotal > 0 {
+		return m.CostTotal
 	}
-
-	var allMessages []tokenMessage
-
-	for _, agentEntry := range entries {
-		if !agentEntry.IsDir() {
-			continue
-		}
-		agentID := agentEntry.Name()
-		sessionsDir := filepath.Join(agentsDir, agentID, "sessions")
-
-		sessionFiles, err := os.ReadDir(sessionsDir)
-		if err != nil {
-			continue
-		}
-
-		for _, sf := range sessionFiles {
-			if !strings.HasSuffix(sf.Name(), ".jsonl") {
-				continue
-			}
-
-			msgs := parseJSONLFile(filepath.Join(sessionsDir, sf.Name()), agentID)
-			allMessages = append(allMessages, msgs...)
-		}
-	}
-
-	return allMessages
+	return priceTokens(m.Model, m.Timestamp, m.Input, m.Output, m.CacheRead, m.CacheWrite)
 }
 
-func parseJSONLFile(path, agentID string) []tokenMessage {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil
+// parseTokenLine extracts usage from one JSONL line. Only assistant
+// messages carry usage, so anything else returns false.
+func parseTokenLine(line []byte, agentID string) (tokenMessage, bool) {
+	var entry map[string]interface{}
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return tokenMessage{}, false
 	}
-	defer f.Close()
 
-	var messages []tokenMessage
-	scanner := bufio.NewScanner(f)
-	scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // 1MB buffer
-
-	for scanner.Scan() {
-		var entry map[string]interface{}
-		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
-			continue
-		}
-
-		// Only assistant messages have usage data
-		if entry["type"] != "message" {
-			continue
-		}
-
-		// Usage is nested inside entry["message"]["usage"]
-		innerMsg, _ := entry["message"].(map[string]interface{})
-		if innerMsg == nil {
-			continue
-		}
-		// Only count assistant messages (they carry usage/cost)
-		if role, _ := innerMsg["role"].(string); role != "assistant" {
-			continue
-		}
-		usage, ok := innerMsg["usage"].(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		var msg tokenMessage
-		msg.AgentID = agentID
-
-		// Parse timestamp (may be at top level or inside message)
-		tsStr := ""
-		if ts, ok := entry["timestamp"].(string); ok {
-			tsStr = ts
-		} else if ts, ok := innerMsg["timestamp"].(string); ok {
-			tsStr = ts
-		}
-		if tsStr != "" {
-			if t, err := time.Parse(time.RFC3339Nano, tsStr); err == nil {
-				msg.Timestamp = t
-			} else if t, err := time.Parse("2006-01-02T15:04:05.000Z", tsStr); err == nil {
-				msg.Timestamp = t
-			}
-		}
-		if msg.Timestamp.IsZero() {
-			msg.Timestamp = time.Now()
-		}
-
-		// Parse model (inside message)
-		if m, ok := innerMsg["model"].(string); ok {
-			msg.Model = m
-		}
+	// Only assistant messages have usage data
+	if entry["type"] != "message" {
+		return tokenMessage{}, false
+	}
 
-		// Parse usage fields
-		if v, ok := usage["input"].(float64); ok {
-			msg.Input = int64(v)
-		}
-		if v, ok := usage["output"].(float64); ok {
-			msg.Output = int64(v)
-		}
-		if v, ok := usage["cacheRead"].(float64); ok {
-			msg.CacheRead = int64(v)
-		}
-		if v, ok := usage["cacheWrite"].(float64); ok {
-			msg.CacheWrite = int64(v)
-		}
-		if v, ok := usage["totalTokens"].(float64); ok {
-			msg.TotalTokens = int64(v)
-		}
+	// Usage is nested inside entry["message"]["usage"]
+	innerMsg, _ := entry["message"].(map[string]interface{})
+	if innerMsg == nil {
+		return tokenMessage{}, false
+	}
+	// Only count assistant messages (they carry usage/cost)
+	if role, _ := innerMsg["role"].(string); role != "assistant" {
+		return tokenMessage{}, false
+	}
+	usage, ok := innerMsg["usage"].(map[string]interface{})
+	if !ok {
+		return tokenMessage{}, false
+	}
 
-		// Parse cost
-		if cost, ok := usage["cost"].(map[string]interface{}); ok {
-			if v, ok := cost["total"].(float64); ok {
-				msg.CostTotal = v
-			}
-		}
+	var msg tokenMessage
+	msg.AgentID = agentID
 
-		// If no cost from JSONL, calculate from model pricing
-		if msg.CostTotal == 0 && msg.Model != "" {
-			msg.CostTotal = calcModelCost(msg.Model, msg.Input+msg.CacheRead+msg.CacheWrite, msg.Output)
+	// Parse timestamp (may be at top level or inside message)
+	tsStr := ""
+	if ts, ok := entry["timestamp"].(string); ok {
+		tsStr = ts
+	} else if ts, ok := innerMsg["timestamp"].(string); ok {
+		tsStr = ts
+	}
+	if tsStr != "" {
+		if t, err := time.Parse(time.RFC3339Nano, tsStr); err == nil {
+			msg.Timestamp = t
+		} else if t, err := time.Parse("2006-01-02T15:04:05.000Z", tsStr); err == nil {
+			msg.Timestamp = t
 		}
-
-		messages = append(messages, msg)
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
 	}
 
-	return messages
-}
+	// Parse model (inside message)
+	if m, ok := innerMsg["model"].(string); ok {
+		msg.Model = m
+	}
 
-func calcModelCost(model string, input, output int64) float64 {
-	// Try exact match first
-	if p, ok := modelPricing[model]; ok {
-		return (float64(input)/1e6)*p.In + (float64(output)/1e6)*p.Out
+	// Parse usage fields
+	if v, ok := usage["input"].(float64); ok {
+		msg.Input = int64(v)
+	}
+	if v, ok := usage["output"].(float64); ok {
+		msg.Output = int64(v)
+	}
+	if v, ok := usage["cacheRead"].(float64); ok {
+		msg.CacheRead = int64(v)
+	}
+	if v, ok := usage["cacheWrite"].(float64); ok {
+		msg.CacheWrite = int64(v)
 	}
-	// Fuzzy match
-	for k, p := range modelPricing {
-		if strings.Contains(model, strings.Split(k, "/")[len(strings.Split(k, "/"))-1]) {
-			return (float64(input)/1e6)*p.In + (float64(output)/1e6)*p.Out
+	if v, ok := usage["totalTokens"].(float64); ok {
+		msg.TotalTokens = int64(v)
+	}
+
+	// Parse cost
+	if cost, ok := usage["cost"].(map[string]interface{}); ok {
+		if v, ok := cost["total"].(float64); ok {
+			msg.CostTotal = v
 		}
 	}
-	// Default to sonnet pricing
-	return (float64(input)/1e6)*3.0 + (float64(output)/1e6)*15.0
+
+	return msg, true
 }
 
 // GetTokens handles GET /api/analytics/tokens — per-agent token usage
 func (h *AnalyticsHandler) GetTokens(w http.ResponseWriter, r *http.Request) {
-	allMsgs := parseAllTokenData()
+	allMsgs := tokenMessages()
 
 	// Aggregate per agent
 	type agentUsage struct {
@@ -208,7 +137,7 @@ func (h *AnalyticsHandler) GetTokens(w http.ResponseWriter, r *http.Request) {
 		au.TokensIn += msg.Input + msg.CacheRead + msg.CacheWrite
 		au.TokensOut += msg.Output
 		au.TotalTokens += msg.TotalTokens
-		au.CostUSD += msg.CostTotal
+		au.CostUSD += msg.Cost()
 	}
 
 	// Resolve display names from config
@@ -241,7 +170,7 @@ func (h *AnalyticsHandler) GetTokensTimeline(w http.ResponseWriter, r *http.Requ
 
 	agentFilter := r.URL.Query().Get("agent")
 
-	allMsgs := parseAllTokenData()
+	allMsgs := tokenMessages()
 	cutoff := time.Now().AddDate(0, 0, -days)
 
 	type dailyUsage struct {
@@ -268,7 +197,7 @@ func (h *AnalyticsHandler) GetTokensTimeline(w http.ResponseWriter, r *http.Requ
 		}
 		du.TokensIn += msg.Input + msg.CacheRead + msg.CacheWrite
 		du.TokensOut += msg.Output
-		du.CostUSD += msg.CostTotal
+		du.CostUSD += msg.Cost()
 	}
 
 	// Fill in missing days
@@ -287,7 +216,7 @@ func (h *AnalyticsHandler) GetTokensTimeline(w http.ResponseWriter, r *http.Requ
 
 // GetCostSummary handles GET /api/analytics/cost/summary
 func (h *AnalyticsHandler) GetCostSummary(w http.ResponseWriter, r *http.Request) {
-	allMsgs := parseAllTokenData()
+	allMsgs := tokenMessages()
 
 	now := time.Now()
 	weekStart := now.AddDate(0, 0, -int(now.Weekday()))
@@ -299,15 +228,15 @@ func (h *AnalyticsHandler) GetCostSummary(w http.ResponseWriter, r *http.Request
 	agentCosts := make(map[string]float64)
 
 	for _, msg := range allMsgs {
-		costAllTime += msg.CostTotal
+		costAllTime += msg.Cost()
 		tokensAllTime += msg.TotalTokens
-		agentCosts[msg.AgentID] += msg.CostTotal
+		agentCosts[msg.AgentID] += msg.Cost()
 
 		if !msg.Timestamp.Before(weekStart) {
-			costThisWeek += msg.CostTotal
+			costThisWeek += msg.Cost()
 		}
 		if !msg.Timestamp.Before(monthStart) {
-			costThisMonth += msg.CostTotal
+			costThisMonth += msg.Cost()
 		}
 	}
 
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/config"
+	"github.com/alghanim/agentboard/backend/db"
+
+	"github.com/lib/pq"
+)
+
+// tokenIndexConsumer names the token index's rows in jsonl_offsets.
+const tokenIndexConsumer = "token_index"
+
+// tokenIndex holds every assistant usage record from the session JSONL files.
+// It is loaded from token_messages at startup and then only parses bytes
+// appended since the last refresh, so the analytics handlers never rescan.
+type tokenIndex struct {
+	refreshMu sync.Mutex // serialises refreshes
+
+	mu      sync.RWMutex
+	loaded  bool
+	offsets map[string]fileOffset
+	all     []tokenMessage // append-only between resets; readers keep their slice
+}
+
+var tokens = &tokenIndex{}
+
+// StartTokenIndexer runs in a goroutine and keeps the token index current.
+func StartTokenIndexer() {
+	tokens.refresh()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		tokens.refresh()
+	}
+}
+
+// tokenMessages returns all indexed usage records. The slice is shared and
+// must not be modified.
+func tokenMessages() []tokenMessage {
+	tokens.mu.RLock()
+	loaded := tokens.loaded
+	all := tokens.all
+	tokens.mu.RUnlock()
+	if loaded {
+		return all
+	}
+
+	// First request before the indexer's initial pass finished.
+	tokens.refresh()
+	tokens.mu.RLock()
+	defer tokens.mu.RUnlock()
+	return tokens.all
+}
+
+// load fills the index from Postgres.
+func (ix *tokenIndex) load() error {
+	offsets, err := loadOffsets(tokenIndexConsumer)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT path, agent_id, ts, COALESCE(model, ''), input, output,
+		       cache_read, cache_write, total_tokens, cost
+		FROM token_messages ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var all []tokenMessage
+	for rows.Next() {
+		var m tokenMessage
+		if err := rows.Scan(&m.File, &m.AgentID, &m.Timestamp, &m.Model, &m.Input, &m.Output,
+			&m.CacheRead, &m.CacheWrite, &m.TotalTokens, &m.CostTotal); err != nil {
+			return err
+		}
+		all = append(all, m)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	ix.mu.Lock()
+	ix.offsets = offsets
+	ix.all = all
+	ix.loaded = true
+	ix.mu.Unlock()
+	return nil
+}
+
+// refresh picks up appended, new and rewritten session files.
+func (ix *tokenIndex) refresh() {
+	ix.refreshMu.Lock()
+	defer ix.refreshMu.Unlock()
+
+	ix.mu.RLock()
+	loaded := ix.loaded
+	ix.mu.RUnlock()
+	if !loaded {
+		start := time.Now()
+		if err := ix.load(); err != nil {
+			// Indexing without the persisted rows would duplicate them; retry next pass.
+			log.Printf("[tokens] load index: %v", err)
+			return
+		}
+		log.Printf("[tokens] loaded %d usage records in %s", len(ix.all), time.Since(start).Round(time.Millisecond))
+	}
+
+	agentsDir := filepath.Join(config.GetOpenClawDir(), "agents")
+	dirs, err := os.ReadDir(agentsDir)
+	if err != nil {
+		return
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		agentID := d.Name()
+		sessionsDir := filepath.Join(agentsDir, agentID, "sessions")
+		files, err := os.ReadDir(sessionsDir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if f.IsDir() || !strings.HasSuffix(f.Name(), ".jsonl") {
+				continue
+			}
+			if err := ix.indexFile(filepath.Join(sessionsDir, f.Name()), agentID); err != nil {
+				log.Printf("[tokens] index %s: %v", f.Name(), err)
+			}
+		}
+	}
+}
+
+// indexFile parses what was appended to one file, persists it together with
+// the new offset, then publishes it in memory.
+func (ix *tokenIndex) indexFile(path, agentID string) error {
+	ix.mu.RLock()
+	prev := ix.offsets[path]
+	ix.mu.RUnlock()
+
+	lines, next, reset, err := readAppended(path, prev)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 && !reset && next.unchangedFrom(prev) {
+		return nil
+	}
+
+	var msgs []tokenMessage
+	for _, line := range lines {
+		if m, ok := parseTokenLine(line, agentID); ok {
+			m.File = path
+			msgs = append(msgs, m)
+		}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if reset {
+		if _, err := tx.Exec(`DELETE FROM token_messages WHERE path = $1`, path); err != nil {
+			return err
+		}
+	}
+	if len(msgs) > 0 {
+		stmt, err := tx.Prepare(pq.CopyIn("token_messages", "path", "agent_id", "ts", "model",
+			"input", "output", "cache_read", "cache_write", "total_tokens", "cost"))
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if _, err := stmt.Exec(m.File, m.AgentID, m.Timestamp, m.Model, m.Input, m.Output,
+				m.CacheRead, m.CacheWrite, m.TotalTokens, m.CostTotal); err != nil {
+				stmt.Close()
+				return err
+			}
+		}
+		if _, err := stmt.Exec(); err != nil {
+			stmt.Close()
+			return err
+		}
+		if err := stmt.Close(); err != nil {
+			return err
+		}
+	}
+	if err := saveOffset(tx, tokenIndexConsumer, path, next); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if reset {
+		// Copy rather than filter in place: readers may hold the old slice.
+		kept := make([]tokenMessage, 0, len(ix.all))
+		for _, m := range ix.all {
+			if m.File != path {
+				kept = append(kept, m)
+			}
+		}
+		ix.all = kept
+	}
+	ix.all = append(ix.all, msgs...)
+	ix.offsets[path] = next
+	return nil
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/config"
+	"github.com/alghanim/agentboard/backend/db"
+)
+
+// transcriptIndexConsumer names the transcript indexer's rows in jsonl_offsets.
+const transcriptIndexConsumer = "transcripts"
+
+// transcriptIndexMaxContent caps how much of one entry is indexed; Postgres
+// tsvectors top out at 1 MB and huge tool outputs add little to search.
+const transcriptIndexMaxContent = 64 * 1024
+
+// StartTranscriptIndexer runs in a goroutine and keeps transcript_entries,
+// the full-text index of every session's messages, tool calls and tool
+// results, current with what agents append.
+func StartTranscriptIndexer() {
+	indexTranscripts()
+
+	ticker := time.NewTicker(60 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		indexTranscripts()
+	}
+}
+
+func indexTranscripts() {
+	offsets, err := loadOffsets(transcriptIndexConsumer)
+	if err != nil {
+		log.Printf("[transcripts] load offsets: %v", err)
+		return
+	}
+
+	agentsDir := filepath.Join(config.GetOpenClawDir(), "agents")
+	dirs, err := os.ReadDir(agentsDir)
+	if err != nil {
+		return
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		agentID := agentIDForDir(d.Name())
+		agent := OCAgent{ID: agentID, Name: agentID}
+		if ca := config.GetAgentByID(agentID); ca != nil {
+			agent = agentFromConfig(*ca)
+		}
+		sessionsDir := filepath.Join(agentsDir, d.Name(), "sessions")
+		files, err := os.ReadDir(sessionsDir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if f.IsDir() || !strings.HasSuffix(f.Name(), ".jsonl") {
+				continue
+			}
+			path := filepath.Join(sessionsDir, f.Name())
+			if err := indexTranscriptFile(path, agent, offsets[path]); err != nil {
+				log.Printf("[transcripts] %s: %v", path, err)
+			}
+		}
+	}
+}
+
+// indexTranscriptFile indexes the entries appended to one session file and
+// advances its offset in the same transaction. Each row records the byte
+// offset of its line and its position among the line's entries, which is
+// the transcript API's cursor.
+func indexTranscriptFile(path string, agent OCAgent, prev fileOffset) error {
+	lines, next, reset, err := readAppendedLines(path, prev)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 && !reset {
+		if !next.unchangedFrom(prev) {
+			return saveOffset(db.DB, transcriptIndexConsumer, path, next)
+		}
+		return nil
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if reset {
+		// The file was rewritten; index it again from scratch.
+		if _, err := tx.Exec(`DELETE FROM transcript_entries WHERE path = $1`, path); err != nil {
+			return err
+		}
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO transcript_entries
+			(agent_id, session_key, path, line_offset, entry_index, ts, role, type, tool_name, is_error, content)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11)
+		ON CONFLICT (path, line_offset, entry_index) DO NOTHING`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	sessionKey := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+	for _, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line.Data, &entry); err != nil {
+			continue
+		}
+		for i, e := range parseStreamEntries(entry, agent, true) {
+			content := e.Content
+			if e.ToolInput != nil {
+				if args, err := json.Marshal(e.ToolInput); err == nil {
+					content += "\n" + string(args)
+				}
+			}
+			if len(content) > transcriptIndexMaxContent {
+				content = strings.ToValidUTF8(content[:transcriptIndexMaxContent], "")
+			}
+			content = strings.ReplaceAll(content, "\x00", "") // Postgres text can't hold NUL
+			if _, err := stmt.Exec(agent.ID, sessionKey, path, line.Offset, i, e.Timestamp,
+				transcriptRole(e.Type), e.Type, e.ToolName, e.IsError, content); err != nil {
+				return err
+			}
+		}
+	}
+	if err := saveOffset(tx, transcriptIndexConsumer, path, next); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
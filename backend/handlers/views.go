@@ -0,0 +1,524 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alghanim/agentboard/backend/auth"
+	"github.com/alghanim/agentboard/backend/db"
+	"github.com/alghanim/agentboard/backend/models"
+	"github.com/alghanim/agentboard/backend/websocket"
+
+	"github.com/gorilla/mux"
+)
+
+type ViewHandler struct {
+	Hub *websocket.Hub
+}
+
+const viewColumns = `id, name, description, filters, sort, shared, created_by, created_at, updated_at`
+
+// ListViews handles GET /api/views
+// Shared views plus the caller's own; holders of views.manage see all.
+func (h *ViewHandler) ListViews(w http.ResponseWriter, r *http.Request) {
+	views, err := queryViews(`SELECT `+viewColumns+` FROM saved_views
+		WHERE shared OR created_by = $1 OR $2 ORDER BY name, created_at`,
+		getAgentFromContext(r), canManageViews(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, views)
+}
+
+// GetView handles GET /api/views/{id}
+func (h *ViewHandler) GetView(w http.ResponseWriter, r *http.Request) {
+	v, ok := loadVisibleView(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, v)
+}
+
+// CreateView handles POST /api/views
+// Body: {"name": "...", "description": "...", "filters": {...},
+// "sort": "-priority,due_date", "shared": true}. See taskFilter for the
+// filter fields; views are shared unless "shared" is false.
+func (h *ViewHandler) CreateView(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+	var data struct {
+		Name        string          `json:"name"`
+		Description *string         `json:"description"`
+		Filters     json.RawMessage `json:"filters"`
+		Sort        string          `json:"sort"`
+		Shared      *bool           `json:"shared"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filters, err := validateView(data.Name, data.Filters, data.Sort)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	shared := data.Shared == nil || *data.Shared
+
+	actor := getAgentFromContext(r)
+	v, err := scanView(db.DB.QueryRow(`
+		INSERT INTO saved_views (name, description, filters, sort, shared, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+viewColumns,
+		strings.TrimSpace(data.Name), models.PtrToNullString(data.Description), filters, data.Sort, shared, actor))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	savedViews.invalidate()
+
+	logActivity(actor, "view_created", "", map[string]string{"view_id": v.ID, "name": v.Name})
+	h.Hub.Broadcast("view_created", v, websocket.Topic("view", v.ID))
+	respondJSON(w, http.StatusCreated, v)
+}
+
+// UpdateView handles PUT /api/views/{id}
+// Accepts the CreateView fields; omitted fields are kept. Only the view's
+// creator and holders of views.manage may change it.
+func (h *ViewHandler) UpdateView(w http.ResponseWriter, r *http.Request) {
+	current, ok := loadOwnView(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+	var data struct {
+		Name        *string         `json:"name"`
+		Description *string         `json:"description"`
+		Filters     json.RawMessage `json:"filters"`
+		Sort        *string         `json:"sort"`
+		Shared      *bool           `json:"shared"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if data.Name != nil {
+		current.Name = *data.Name
+	}
+	if data.Description != nil {
+		current.Description = data.Description
+	}
+	if data.Filters != nil {
+		current.Filters = data.Filters
+	}
+	if data.Sort != nil {
+		current.Sort = *data.Sort
+	}
+	if data.Shared != nil {
+		current.Shared = *data.Shared
+	}
+	filters, err := validateView(current.Name, current.Filters, current.Sort)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	v, err := scanView(db.DB.QueryRow(`
+		UPDATE saved_views SET name = $1, description = $2, filters = $3, sort = $4, shared = $5,
+		       updated_at = NOW()
+		WHERE id = $6
+		RETURNING `+viewColumns,
+		strings.TrimSpace(current.Name), models.PtrToNullString(current.Description), filters,
+		current.Sort, current.Shared, current.ID))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	savedViews.invalidate()
+
+	logActivity(getAgentFromContext(r), "view_updated", "", map[string]string{"view_id": v.ID, "name": v.Name})
+	h.Hub.Broadcast("view_updated", v, websocket.Topic("view", v.ID))
+	respondJSON(w, http.StatusOK, v)
+}
+
+// DeleteView handles DELETE /api/views/{id}
+func (h *ViewHandler) DeleteView(w http.ResponseWriter, r *http.Request) {
+	v, ok := loadOwnView(w, r)
+	if !ok {
+		return
+	}
+	if _, err := db.DB.Exec(`DELETE FROM saved_views WHERE id = $1`, v.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	savedViews.invalidate()
+
+	logActivity(getAgentFromContext(r), "view_deleted", "", map[string]string{"view_id": v.ID, "name": v.Name})
+	h.Hub.Broadcast("view_deleted", map[string]string{"id": v.ID}, websocket.Topic("view", v.ID))
+	respondJSON(w, http.StatusOK, map[string]string{"message": "View deleted"})
+}
+
+// GetViewTasks handles GET /api/views/{id}/tasks
+// Runs the view for the caller ("me" and "my_team" subtrees are theirs) and
+// returns its tasks as GET /api/tasks does, with limit (default 100, max
+// 500) and offset.
+func (h *ViewHandler) GetViewTasks(w http.ResponseWriter, r *http.Request) {
+	v, ok := loadVisibleView(w, r)
+	if !ok {
+		return
+	}
+	f, err := decodeTaskFilter(v.Filters)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var args sqlArgs
+	conds, err := f.conds(&args, getAgentFromContext(r))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	orderBy, err := taskOrderBy(v.Sort)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	limit, offset := 100, 0
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = n
+		if limit > 500 {
+			limit = 500
+		}
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && n >= 0 {
+		offset = n
+	}
+	query := `SELECT ` + taskColumns + ` FROM tasks t WHERE ` + strings.Join(append([]string{"TRUE"}, conds...), " AND ") +
+		` ORDER BY ` + orderBy + ` LIMIT ` + args.add(limit) + ` OFFSET ` + args.add(offset)
+	tasks, err := queryTasks(query, args...)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if tasks == nil {
+		tasks = []models.Task{}
+	}
+
+	etag := taskListETag(tasks)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	respondJSON(w, http.StatusOK, tasks)
+}
+
+// validateView checks a view definition and returns its filters normalised
+// for storage.
+func validateView(name string, filters json.RawMessage, sort string) ([]byte, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	f, err := decodeTaskFilter(filters)
+	if err != nil {
+		return nil, fmt.Errorf("filters: %v", err)
+	}
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := taskOrderBy(sort); err != nil {
+		return nil, err
+	}
+	return json.Marshal(f)
+}
+
+// decodeTaskFilter parses a stored or submitted filter definition, rejecting
+// unknown fields. Empty means every task.
+func decodeTaskFilter(raw json.RawMessage) (taskFilter, error) {
+	var f taskFilter
+	if len(raw) == 0 || string(raw) == "null" {
+		return f, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&f)
+	return f, err
+}
+
+// canManageViews reports whether the caller holds views.manage. Without
+// auth there is nothing to check it against, so nobody does.
+func canManageViews(r *http.Request) bool {
+	return auth.Enabled() && authorize(r, "views.manage", policyTarget{}) == ""
+}
+
+// loadVisibleView loads the view named in the URL, answering 404 if it
+// doesn't exist or is another caller's unshared view.
+func loadVisibleView(w http.ResponseWriter, r *http.Request) (models.SavedView, bool) {
+	v, err := scanView(db.DB.QueryRow(`SELECT `+viewColumns+` FROM saved_views WHERE id::text = $1`, mux.Vars(r)["id"]))
+	if err == nil && !v.Shared && v.CreatedBy != getAgentFromContext(r) && !canManageViews(r) {
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "View not found")
+		return v, false
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return v, false
+	}
+	return v, true
+}
+
+// loadOwnView is loadVisibleView for a change: anyone but the creator needs
+// views.manage.
+func loadOwnView(w http.ResponseWriter, r *http.Request) (models.SavedView, bool) {
+	v, ok := loadVisibleView(w, r)
+	if !ok {
+		return v, false
+	}
+	if v.CreatedBy != getAgentFromContext(r) && !allow(w, r, "views.manage", policyTarget{}) {
+		return v, false
+	}
+	return v, true
+}
+
+func scanView(row rowScanner) (models.SavedView, error) {
+	var v models.SavedView
+	var desc sql.NullString
+	var filters []byte
+	if err := row.Scan(&v.ID, &v.Name, &desc, &filters, &v.Sort, &v.Shared, &v.CreatedBy,
+		&v.CreatedAt, &v.UpdatedAt); err != nil {
+		return v, err
+	}
+	v.Description = models.NullStringToPtr(desc)
+	v.Filters = json.RawMessage(filters)
+	return v, nil
+}
+
+func queryViews(query string, args ...interface{}) ([]models.SavedView, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	views := []models.SavedView{}
+	for rows.Next() {
+		v, err := scanView(rows)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, v)
+	}
+	return views, rows.Err()
+}
+
+// viewWatch tracks which saved views each task is in, so events about a
+// task also go to the subscribers of its views (topic view:<id>), including
+// a view the change just took it out of. "me" and "my_team" subtrees are
+// evaluated for the view's creator. Views are matched against the task in
+// memory, and vw.mu is never held across a database call.
+type viewWatch struct {
+	mu      sync.Mutex
+	hub     subscriptions       // nil counts as always watched
+	gen     int                 // bumped by invalidate
+	views   []watchedView       // nil until loaded
+	members map[string][]string // task ID → IDs of the views it was last in
+}
+
+var savedViews = &viewWatch{}
+
+// subscriptions is the part of websocket.Hub that viewWatch needs.
+type subscriptions interface {
+	Subscribed(prefix string) bool
+}
+
+// WatchSavedViews sends task events to saved-view subscribers on hub. View
+// topics are only worked out while someone is subscribed to a view.
+func WatchSavedViews(hub subscriptions) {
+	savedViews.mu.Lock()
+	defer savedViews.mu.Unlock()
+	savedViews.hub = hub
+}
+
+// watchedView is a saved view ready to be matched against a task.
+type watchedView struct {
+	id      string
+	filter  taskFilter
+	subtree map[string]bool // the subtree's assignees; nil without one
+	broken  bool            // can't be evaluated for its creator, so matches nothing
+}
+
+func compileView(v models.SavedView) watchedView {
+	wv := watchedView{id: v.ID}
+	f, err := decodeTaskFilter(v.Filters)
+	if err != nil {
+		wv.broken = true
+		return wv
+	}
+	wv.filter = f
+	if f.Subtree != "" {
+		root, err := subtreeRoot(f.Subtree, v.CreatedBy)
+		if err != nil {
+			wv.broken = true
+			return wv
+		}
+		wv.subtree = make(map[string]bool)
+		for _, a := range subtreeAssignees(root) {
+			wv.subtree[a] = true
+		}
+	}
+	return wv
+}
+
+// matchingViews returns the IDs of the views task is in.
+func matchingViews(views []watchedView, task models.Task) []string {
+	var ids []string
+	for _, v := range views {
+		if !v.broken && v.filter.matches(task, v.subtree) {
+			ids = append(ids, v.id)
+		}
+	}
+	return ids
+}
+
+// invalidate drops the cached views after one is created, changed or
+// deleted; they are reloaded on the next task event.
+func (vw *viewWatch) invalidate() {
+	vw.mu.Lock()
+	defer vw.mu.Unlock()
+	vw.gen++
+	vw.views, vw.members = nil, nil
+}
+
+// snapshot returns the views and the generation they belong to, loading
+// them if needed: every view, and in one pass over the tasks, the views
+// each task is in now.
+func (vw *viewWatch) snapshot() ([]watchedView, int, error) {
+	vw.mu.Lock()
+	views, gen := vw.views, vw.gen
+	vw.mu.Unlock()
+	if views != nil {
+		return views, gen, nil
+	}
+
+	saved, err := queryViews(`SELECT ` + viewColumns + ` FROM saved_views`)
+	if err != nil {
+		return nil, 0, err
+	}
+	views = make([]watchedView, len(saved))
+	for i, v := range saved {
+		views[i] = compileView(v)
+	}
+	members := make(map[string][]string)
+	if len(views) > 0 {
+		rows, err := db.DB.Query(`SELECT ` + taskColumns + ` FROM tasks`)
+		if err != nil {
+			return nil, 0, err
+		}
+		for rows.Next() {
+			t, err := scanTask(rows)
+			if err != nil {
+				rows.Close()
+				return nil, 0, err
+			}
+			if ids := matchingViews(views, t); len(ids) > 0 {
+				members[t.ID] = ids
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	vw.mu.Lock()
+	if vw.gen == gen && vw.views == nil {
+		vw.views, vw.members = views, members
+	}
+	vw.mu.Unlock()
+	return views, gen, nil
+}
+
+// topics returns the view topics for an event about task, in its current
+// state: the views it is in now and the ones it was in before.
+func (vw *viewWatch) topics(task models.Task) []string {
+	if !vw.watching() {
+		return nil
+	}
+	views, gen, err := vw.snapshot()
+	if err != nil {
+		log.Printf("[views] load: %v", err)
+		return nil
+	}
+	if len(views) == 0 {
+		return nil
+	}
+	return vw.swap(task.ID, gen, matchingViews(views, task))
+}
+
+// forget returns the view topics for an event about a task that no longer
+// exists: the views it was in.
+func (vw *viewWatch) forget(taskID string) []string {
+	if !vw.watching() {
+		return nil
+	}
+	vw.mu.Lock()
+	gen := vw.gen
+	vw.mu.Unlock()
+	return vw.swap(taskID, gen, nil)
+}
+
+// watching reports whether anyone is subscribed to a view. While nobody is,
+// memberships aren't kept up to date, so the cache is dropped and rebuilt
+// once someone subscribes.
+func (vw *viewWatch) watching() bool {
+	vw.mu.Lock()
+	hub := vw.hub
+	vw.mu.Unlock()
+	if hub == nil || hub.Subscribed("view:") {
+		return true
+	}
+	vw.mu.Lock()
+	if vw.views != nil {
+		vw.gen++
+		vw.views, vw.members = nil, nil
+	}
+	vw.mu.Unlock()
+	return false
+}
+
+// swap records the views a task is in now and returns the topics of those
+// and of the ones it was in before. now is ignored if the views changed
+// since generation gen was loaded.
+func (vw *viewWatch) swap(taskID string, gen int, now []string) []string {
+	vw.mu.Lock()
+	var before []string
+	if vw.gen == gen && vw.members != nil {
+		before = vw.members[taskID]
+		if len(now) > 0 {
+			vw.members[taskID] = now
+		} else {
+			delete(vw.members, taskID)
+		}
+	}
+	vw.mu.Unlock()
+
+	var topics []string
+	seen := make(map[string]bool)
+	for _, id := range append(before, now...) {
+		if !seen[id] {
+			seen[id] = true
+			topics = append(topics, websocket.Topic("view", id))
+		}
+	}
+	return topics
+}
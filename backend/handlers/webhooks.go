@@ -0,0 +1,556 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/alghanim/agentboard/backend/db"
+	"github.com/alghanim/agentboard/backend/models"
+	"github.com/alghanim/agentboard/backend/websocket"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// Webhook retry policy: attempt n (1-based) that fails is retried after
+// webhookBackoff·2^(n-1), capped at webhookMaxBackoff, until
+// webhookMaxAttempts is reached and the delivery is marked failed.
+const (
+	webhookMaxAttempts = 8
+	webhookBackoff     = 30 * time.Second
+	webhookMaxBackoff  = time.Hour
+)
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// webhookWake nudges the delivery worker when deliveries are queued.
+var webhookWake = make(chan struct{}, 1)
+
+type WebhookHandler struct{}
+
+const webhookColumns = `id, url, events, description, active, created_by, created_at, updated_at`
+
+const deliveryColumns = `id, webhook_id, event_type, payload, status, attempts, next_attempt_at,
+	response_code, last_error, redelivery_of, created_at, delivered_at`
+
+// ListWebhooks handles GET /api/webhooks
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !allowAlways(w, r, "webhooks.manage", policyTarget{}) {
+		return
+	}
+
+	rows, err := db.DB.Query(`SELECT ` + webhookColumns + ` FROM webhooks ORDER BY created_at`)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	hooks := []models.Webhook{}
+	for rows.Next() {
+		wh, err := scanWebhook(rows)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		hooks = append(hooks, wh)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(w, http.StatusInternalServerError, "row iteration error: "+err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, hooks)
+}
+
+// GetWebhook handles GET /api/webhooks/{id}
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	if !allowAlways(w, r, "webhooks.manage", policyTarget{}) {
+		return
+	}
+
+	wh, err := scanWebhook(db.DB.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id::text = $1`, mux.Vars(r)["id"]))
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, wh)
+}
+
+// CreateWebhook handles POST /api/webhooks
+// Body: {"url": "https://...", "events": ["task_*", "comment_added"],
+// "description": "...", "secret": "..."}. Events are glob patterns over
+// event types; none means every event. A secret is generated if omitted and
+// is only returned here.
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !allowAlways(w, r, "webhooks.manage", policyTarget{}) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+	var data struct {
+		URL         string   `json:"url"`
+		Events      []string `json:"events"`
+		Description *string  `json:"description"`
+		Secret      string   `json:"secret"`
+		Active      *bool    `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateWebhook(data.URL, data.Events); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if data.Secret == "" {
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		data.Secret = "whsec_" + hex.EncodeToString(b)
+	}
+	if data.Events == nil {
+		data.Events = []string{}
+	}
+	active := data.Active == nil || *data.Active
+
+	actor := getAgentFromContext(r)
+	wh, err := scanWebhook(db.DB.QueryRow(`
+		INSERT INTO webhooks (url, secret, events, description, active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+webhookColumns,
+		data.URL, data.Secret, pq.Array(data.Events), models.PtrToNullString(data.Description), active, actor))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	wh.Secret = data.Secret
+
+	logActivity(actor, "webhook_created", "", map[string]string{"webhook_id": wh.ID, "url": wh.URL})
+	respondJSON(w, http.StatusCreated, wh)
+}
+
+// UpdateWebhook handles PUT /api/webhooks/{id}
+// Accepts the CreateWebhook fields plus "active"; omitted fields are kept.
+// Setting "secret" rotates it.
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !allowAlways(w, r, "webhooks.manage", policyTarget{}) {
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+	var data struct {
+		URL         *string   `json:"url"`
+		Events      *[]string `json:"events"`
+		Description *string   `json:"description"`
+		Secret      *string   `json:"secret"`
+		Active      *bool     `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	current, err := scanWebhook(db.DB.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id::text = $1`, id))
+	if err == sql.ErrNoRows {
+		respondError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if data.URL != nil {
+		current.URL = *data.URL
+	}
+	if data.Events != nil {
+		current.Events = *data.Events
+	}
+	if data.Description != nil {
+		current.Description = data.Description
+	}
+	if data.Active != nil {
+		current.Active = *data.Active
+	}
+	if err := validateWebhook(current.URL, current.Events); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if current.Events == nil {
+		current.Events = []string{}
+	}
+
+	wh, err := scanWebhook(db.DB.QueryRow(`
+		UPDATE webhooks SET url = $1, events = $2, description = $3, active = $4,
+		       secret = COALESCE($5, secret), updated_at = NOW()
+		WHERE id = $6
+		RETURNING `+webhookColumns,
+		current.URL, pq.Array(current.Events), models.PtrToNullString(current.Description),
+		current.Active, models.PtrToNullString(data.Secret), current.ID))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	logActivity(getAgentFromContext(r), "webhook_updated", "", map[string]string{"webhook_id": wh.ID, "url": wh.URL})
+	respondJSON(w, http.StatusOK, wh)
+}
+
+// DeleteWebhook handles DELETE /api/webhooks/{id}
+// The webhook's delivery log goes with it.
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if !allowAlways(w, r, "webhooks.manage", policyTarget{}) {
+		return
+	}
+	id := mux.Vars(r)["id"]
+
+	result, err := db.DB.Exec(`DELETE FROM webhooks WHERE id::text = $1`, id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+
+	logActivity(getAgentFromContext(r), "webhook_deleted", "", map[string]string{"webhook_id": id})
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Webhook deleted"})
+}
+
+// GetDeliveries handles GET /api/webhooks/{id}/deliveries?status=<status>&limit=<n>
+// Returns the delivery log, newest first (default limit 50, max 500).
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !allowAlways(w, r, "webhooks.manage", policyTarget{}) {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	status := r.URL.Query().Get("status")
+	limit := 50
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	deliveries, err := queryDeliveries(`
+		SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE webhook_id::text = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3`, id, status, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, deliveries)
+}
+
+// Redeliver handles POST /api/webhooks/{id}/deliveries/{delivery_id}/redeliver
+// Queues a fresh delivery of the same payload; the original stays in the log.
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	if !allowAlways(w, r, "webhooks.manage", policyTarget{}) {
+		return
+	}
+	vars := mux.Vars(r)
+
+	deliveries, err := queryDeliveries(`
+		INSERT INTO webhook_deliveries (webhook_id, event_type, payload, redelivery_of)
+		SELECT webhook_id, event_type, payload, id FROM webhook_deliveries
+		WHERE id::text = $1 AND webhook_id::text = $2
+		RETURNING `+deliveryColumns, vars["delivery_id"], vars["id"])
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(deliveries) == 0 {
+		respondError(w, http.StatusNotFound, "Delivery not found")
+		return
+	}
+	wakeWebhooks()
+
+	logActivity(getAgentFromContext(r), "webhook_redelivered", "", map[string]string{
+		"webhook_id": vars["id"], "delivery_id": vars["delivery_id"], "redelivery_id": deliveries[0].ID,
+	})
+	respondJSON(w, http.StatusAccepted, deliveries[0])
+}
+
+// StartWebhookDispatcher runs in a goroutine. It queues a delivery for every
+// hub broadcast matched by an active webhook, and sends due deliveries,
+// retrying failures with exponential backoff. Pending deliveries live in the
+// database, so retries survive a restart.
+func StartWebhookDispatcher(hub *websocket.Hub) {
+	events := make(chan *websocket.Message, 1024)
+	hub.Listen(func(m *websocket.Message) {
+		select {
+		case events <- m:
+		default:
+			log.Printf("[webhooks] event queue full, dropping %s", m.Type)
+		}
+	})
+
+	go func() {
+		for m := range events {
+			if err := enqueueWebhookDeliveries(m); err != nil {
+				log.Printf("[webhooks] queue %s: %v", m.Type, err)
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-webhookWake:
+		}
+		deliverDueWebhooks()
+	}
+}
+
+// enqueueWebhookDeliveries records a pending delivery of m for each active
+// webhook whose filters match its type.
+func enqueueWebhookDeliveries(m *websocket.Message) error {
+	rows, err := db.DB.Query(`SELECT id, events FROM webhooks WHERE active`)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		var events pq.StringArray
+		if err := rows.Scan(&id, &events); err != nil {
+			rows.Close()
+			return err
+		}
+		if webhookMatches(events, m.Type) {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if _, err := db.DB.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+		SELECT id, $2, $3 FROM unnest($1::uuid[]) AS id`,
+		pq.Array(ids), m.Type, body); err != nil {
+		return err
+	}
+	wakeWebhooks()
+	return nil
+}
+
+// deliverDueWebhooks sends every pending delivery whose next attempt is due.
+func deliverDueWebhooks() {
+	type due struct {
+		id, event, url, secret string
+		payload                []byte
+		attempts               int
+	}
+	rows, err := db.DB.Query(`
+		SELECT d.id, d.event_type, d.payload, d.attempts, w.url, w.secret
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND w.active
+		ORDER BY d.next_attempt_at
+		LIMIT 100`)
+	if err != nil {
+		log.Printf("[webhooks] load due deliveries: %v", err)
+		return
+	}
+	var batch []due
+	for rows.Next() {
+		var d due
+		if err := rows.Scan(&d.id, &d.event, &d.payload, &d.attempts, &d.url, &d.secret); err != nil {
+			log.Printf("[webhooks] scan delivery: %v", err)
+			continue
+		}
+		batch = append(batch, d)
+	}
+	rows.Close()
+
+	for _, d := range batch {
+		code, err := sendWebhook(d.url, d.secret, d.id, d.event, d.payload)
+		attempts := d.attempts + 1
+		var respCode interface{}
+		if code > 0 {
+			respCode = code
+		}
+		if err == nil {
+			_, err = db.DB.Exec(`
+				UPDATE webhook_deliveries SET status = 'succeeded', attempts = $2, response_code = $3,
+				       last_error = NULL, next_attempt_at = NULL, delivered_at = NOW()
+				WHERE id = $1`, d.id, attempts, respCode)
+			if err != nil {
+				log.Printf("[webhooks] record delivery %s: %v", d.id, err)
+			}
+			continue
+		}
+
+		status, next := "pending", interface{}(time.Now().Add(webhookRetryDelay(attempts)))
+		if attempts >= webhookMaxAttempts {
+			status, next = "failed", nil
+		}
+		if _, dbErr := db.DB.Exec(`
+			UPDATE webhook_deliveries SET status = $2, attempts = $3, response_code = $4,
+			       last_error = $5, next_attempt_at = $6
+			WHERE id = $1`, d.id, status, attempts, respCode, err.Error(), next); dbErr != nil {
+			log.Printf("[webhooks] record delivery %s: %v", d.id, dbErr)
+		}
+	}
+}
+
+// sendWebhook POSTs one delivery. The body is signed with HMAC-SHA256 using
+// the webhook's secret, in X-AgentBoard-Signature as "sha256=<hex>".
+func sendWebhook(target, secret, deliveryID, event string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AgentBoard-Webhook/1.0")
+	req.Header.Set("X-AgentBoard-Event", event)
+	req.Header.Set("X-AgentBoard-Delivery", deliveryID)
+	req.Header.Set("X-AgentBoard-Signature", "sha256="+signWebhook(secret, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(snippet))
+}
+
+// signWebhook returns the hex HMAC-SHA256 of body under secret.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay is the wait after the given number of failed attempts.
+func webhookRetryDelay(attempts int) time.Duration {
+	d := webhookBackoff
+	for i := 1; i < attempts && d < webhookMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > webhookMaxBackoff {
+		d = webhookMaxBackoff
+	}
+	return d
+}
+
+// webhookMatches reports whether an event type passes a webhook's filters.
+func webhookMatches(patterns []string, eventType string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, eventType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func validateWebhook(rawURL string, events []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL")
+	}
+	for _, p := range events {
+		if p == "" {
+			return fmt.Errorf("events must not contain empty patterns")
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid event pattern %q", p)
+		}
+	}
+	return nil
+}
+
+func wakeWebhooks() {
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+func scanWebhook(row rowScanner) (models.Webhook, error) {
+	var wh models.Webhook
+	var desc, createdBy sql.NullString
+	if err := row.Scan(&wh.ID, &wh.URL, &wh.Events, &desc, &wh.Active, &createdBy,
+		&wh.CreatedAt, &wh.UpdatedAt); err != nil {
+		return wh, err
+	}
+	wh.Description = models.NullStringToPtr(desc)
+	wh.CreatedBy = models.NullStringToPtr(createdBy)
+	if wh.Events == nil {
+		wh.Events = pq.StringArray{}
+	}
+	return wh, nil
+}
+
+func queryDeliveries(query string, args ...interface{}) ([]models.WebhookDelivery, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload []byte
+		var next, delivered sql.NullTime
+		var code sql.NullInt64
+		var lastErr, redeliveryOf sql.NullString
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventType, &payload, &d.Status, &d.Attempts,
+			&next, &code, &lastErr, &redeliveryOf, &d.CreatedAt, &delivered); err != nil {
+			return nil, err
+		}
+		d.Payload = json.RawMessage(payload)
+		d.NextAttemptAt = models.NullTimeToPtr(next)
+		if code.Valid {
+			c := int(code.Int64)
+			d.ResponseCode = &c
+		}
+		d.LastError = models.NullStringToPtr(lastErr)
+		d.RedeliveryOf = models.NullStringToPtr(redeliveryOf)
+		d.DeliveredAt = models.NullTimeToPtr(delivered)
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alghanim/agentboard/backend/config"
+)
+
+type WorkflowHandler struct{}
+
+// GetWorkflows handles GET /api/workflows?team=<name>
+// Without a team it lists every workflow; with one it returns the workflow that team uses.
+func (h *WorkflowHandler) GetWorkflows(w http.ResponseWriter, r *http.Request) {
+	if team := r.URL.Query().Get("team"); team != "" {
+		respondJSON(w, http.StatusOK, config.GetWorkflowForTeam(team))
+		return
+	}
+	respondJSON(w, http.StatusOK, config.GetWorkflows())
+}
+
+// workflowFor resolves the workflow governing a task: the task's own team
+// first, then the assignee's team from config.
+func workflowFor(team, assignee *string) config.Workflow {
+	if team != nil && *team != "" {
+		return config.GetWorkflowForTeam(*team)
+	}
+	if assignee != nil && *assignee != "" {
+		ca := config.GetAgentByID(*assignee)
+		if ca == nil {
+			ca = config.GetAgent(*assignee)
+		}
+		if ca != nil {
+			return config.GetWorkflowForTeam(ca.Team)
+		}
+	}
+	return config.GetWorkflowForTeam("")
+}
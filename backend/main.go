@@ -7,8 +7,10 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/alghanim/agentboard/backend/auth"
 	"github.com/alghanim/agentboard/backend/config"
 	"github.com/alghanim/agentboard/backend/db"
 	"github.com/alghanim/agentboard/backend/handlers"
@@ -48,10 +50,20 @@ func main() {
 		log.Printf("⚠️  Failed to seed agents from config: %v", err)
 	}
 
-	// WebSocket hub
-	hub := websocket.NewHub()
+	// WebSocket hub — recent broadcasts are kept for resume, optionally in Postgres too
+	hubOpts := websocket.Options{
+		ReplaySize:  getEnvInt("WS_REPLAY_SIZE", 1000),
+		StoreReplay: getEnvInt("WS_REPLAY_RETAIN", 10000),
+	}
+	if getEnv("WS_REPLAY_STORE", "memory") == "postgres" {
+		hubOpts.Store = websocket.NewPostgresStore(db.DB, hubOpts.StoreReplay)
+	}
+	hub := websocket.NewHub(hubOpts)
 	go hub.Run()
 
+	// Saved-view topics (view:<id>) are only computed while someone subscribes to one
+	handlers.WatchSavedViews(hub)
+
 	// Handlers
 	taskHandler := &handlers.TaskHandler{Hub: hub}
 	agentHandler := &handlers.AgentHandler{}
@@ -61,27 +73,79 @@ func main() {
 	openclawHandler := &handlers.OpenClawHandler{}
 	analyticsHandler := &handlers.AnalyticsHandler{}
 	brandingHandler := &handlers.BrandingHandler{}
+	pricingHandler := &handlers.PricingHandler{}
+	budgetHandler := &handlers.BudgetHandler{}
+	keyHandler := &handlers.KeyHandler{}
+	webhookHandler := &handlers.WebhookHandler{}
+	viewHandler := &handlers.ViewHandler{Hub: hub}
+	labelHandler := &handlers.LabelHandler{Hub: hub}
 	searchHandler := &handlers.SearchHandler{}
 	performanceHandler := &handlers.PerformanceHandler{}
+	workflowHandler := &handlers.WorkflowHandler{}
+
+	// Agent status — recomputed when session files change, written to the agents table
+	go handlers.StartAgentStatusTracker(hub)
+
+	// Return tasks with expired claim leases to the queue
+	go handlers.StartLeaseReaper(hub)
+
+	// Escalate stuck and overdue tasks to leads
+	go handlers.StartEscalationScheduler(hub)
+
+	// Load OpenClaw session transcripts into agent_sessions / agent_metrics
+	go handlers.StartSessionIngester()
+
+	// Push transcript lines as stream_entry events as agents write them, and
+	// tell the status tracker which agents' session files changed
+	go handlers.StartTranscriptWatcher(hub)
+
+	// Incremental token usage index for the analytics endpoints
+	go handlers.StartTokenIndexer()
+
+	// Full-text index of session transcripts for /api/search
+	go handlers.StartTranscriptIndexer()
+
+	// Budget alerts (budget_warning / budget_exceeded)
+	go handlers.StartBudgetWatcher(hub)
 
-	// Agent status poller
-	go handlers.StartAgentStatusPoller(hub)
+	// Outbound webhooks — queue broadcast events and retry failed deliveries
+	go handlers.StartWebhookDispatcher(hub)
+
+	// Authentication — bearer tokens from api_keys, plus an optional bootstrap ADMIN_TOKEN
+	auth.Configure(auth.Options{
+		Enabled:    getEnv("AUTH_ENABLED", "false") == "true",
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+		Public:     []string{"/api/branding"},
+	})
+	if !auth.Enabled() {
+		log.Printf("⚠️  AUTH_ENABLED is not set: the API is open and X-Agent-ID is trusted")
+	}
 
 	// Router
 	router := mux.NewRouter()
 	api := router.PathPrefix("/api").Subrouter()
+	api.Use(auth.Middleware)
 
 	// Task routes  — static route MUST come before parameterised {id} route
 	api.HandleFunc("/tasks", taskHandler.GetTasks).Methods("GET")
 	api.HandleFunc("/tasks", taskHandler.CreateTask).Methods("POST")
 	api.HandleFunc("/tasks/mine", taskHandler.GetMyTasks).Methods("GET")
 	api.HandleFunc("/tasks/stuck", taskHandler.GetStuckTasks).Methods("GET")
+	api.HandleFunc("/tasks/claim", taskHandler.ClaimTask).Methods("POST")
 	api.HandleFunc("/tasks/{id}", taskHandler.GetTask).Methods("GET")
 	api.HandleFunc("/tasks/{id}", taskHandler.UpdateTask).Methods("PUT")
+	api.HandleFunc("/tasks/{id}", taskHandler.PatchTask).Methods("PATCH")
 	api.HandleFunc("/tasks/{id}", taskHandler.DeleteTask).Methods("DELETE")
 	api.HandleFunc("/tasks/{id}/assign", taskHandler.AssignTask).Methods("POST")
 	api.HandleFunc("/tasks/{id}/transition", taskHandler.TransitionTask).Methods("POST")
+	api.HandleFunc("/tasks/{id}/heartbeat", taskHandler.HeartbeatTask).Methods("POST")
 	api.HandleFunc("/tasks/{id}/history", taskHandler.GetTaskHistory).Methods("GET")
+	api.HandleFunc("/tasks/{id}/dependencies", taskHandler.GetDependencies).Methods("GET")
+	api.HandleFunc("/tasks/{id}/dependencies", taskHandler.AddDependency).Methods("POST")
+	api.HandleFunc("/tasks/{id}/dependencies/{depends_on}", taskHandler.RemoveDependency).Methods("DELETE")
+
+	// Workflows (statuses and transitions from config)
+	api.HandleFunc("/workflows", workflowHandler.GetWorkflows).Methods("GET")
 
 	// Comment routes
 	api.HandleFunc("/tasks/{task_id}/comments", commentHandler.GetComments).Methods("GET")
@@ -94,6 +158,7 @@ func main() {
 	api.HandleFunc("/agents/{id}/activity", agentHandler.GetAgentActivity).Methods("GET")
 	api.HandleFunc("/agents/{id}/metrics", agentHandler.GetAgentMetrics).Methods("GET")
 	api.HandleFunc("/agents/{id}/status", agentHandler.UpdateAgentStatus).Methods("PUT")
+	api.HandleFunc("/agents/{id}/status-history", agentHandler.GetStatusHistory).Methods("GET")
 
 	// Soul endpoint — reads live workspace files
 	api.HandleFunc("/agents/{id}/soul", openclawHandler.GetAgentSoul).Methods("GET")
@@ -101,6 +166,10 @@ func main() {
 	// Skills endpoint — reads global + agent-specific skills
 	api.HandleFunc("/agents/{id}/skills", openclawHandler.GetAgentSkills).Methods("GET")
 
+	// Session browser — every transcript file, paged in full
+	api.HandleFunc("/agents/{id}/sessions", openclawHandler.GetAgentSessions).Methods("GET")
+	api.HandleFunc("/agents/{id}/sessions/{sessionKey}/transcript", openclawHandler.GetSessionTranscript).Methods("GET")
+
 	// Activity
 	api.HandleFunc("/activity", activityHandler.GetActivity).Methods("GET")
 
@@ -117,6 +186,43 @@ func main() {
 	// Branding
 	api.HandleFunc("/branding", brandingHandler.GetBranding).Methods("GET")
 
+	// Pricing
+	api.HandleFunc("/pricing", pricingHandler.GetPricing).Methods("GET")
+
+	// Budgets
+	api.HandleFunc("/budgets", budgetHandler.GetBudgets).Methods("GET")
+
+	// API keys
+	api.HandleFunc("/keys", keyHandler.ListKeys).Methods("GET")
+	api.HandleFunc("/keys", keyHandler.CreateKey).Methods("POST")
+	api.HandleFunc("/keys/{id}", keyHandler.RevokeKey).Methods("DELETE")
+	api.HandleFunc("/auth/me", keyHandler.GetMe).Methods("GET")
+
+	// Webhooks
+	api.HandleFunc("/webhooks", webhookHandler.ListWebhooks).Methods("GET")
+	api.HandleFunc("/webhooks", webhookHandler.CreateWebhook).Methods("POST")
+	api.HandleFunc("/webhooks/{id}", webhookHandler.GetWebhook).Methods("GET")
+	api.HandleFunc("/webhooks/{id}", webhookHandler.UpdateWebhook).Methods("PUT")
+	api.HandleFunc("/webhooks/{id}", webhookHandler.DeleteWebhook).Methods("DELETE")
+	api.HandleFunc("/webhooks/{id}/deliveries", webhookHandler.GetDeliveries).Methods("GET")
+	api.HandleFunc("/webhooks/{id}/deliveries/{delivery_id}/redeliver", webhookHandler.Redeliver).Methods("POST")
+
+	// Saved views — named task filters; task events reach view:{id} subscribers
+	api.HandleFunc("/views", viewHandler.ListViews).Methods("GET")
+	api.HandleFunc("/views", viewHandler.CreateView).Methods("POST")
+	api.HandleFunc("/views/{id}", viewHandler.GetView).Methods("GET")
+	api.HandleFunc("/views/{id}", viewHandler.UpdateView).Methods("PUT")
+	api.HandleFunc("/views/{id}", viewHandler.DeleteView).Methods("DELETE")
+	api.HandleFunc("/views/{id}/tasks", viewHandler.GetViewTasks).Methods("GET")
+
+	// Label catalog; merge and rename rewrite the labels on existing tasks
+	api.HandleFunc("/labels", labelHandler.ListLabels).Methods("GET")
+	api.HandleFunc("/labels", labelHandler.CreateLabel).Methods("POST")
+	api.HandleFunc("/labels/merge", labelHandler.MergeLabels).Methods("POST")
+	api.HandleFunc("/labels/{id}", labelHandler.GetLabel).Methods("GET")
+	api.HandleFunc("/labels/{id}", labelHandler.UpdateLabel).Methods("PUT")
+	api.HandleFunc("/labels/{id}", labelHandler.DeleteLabel).Methods("DELETE")
+
 	// Analytics
 	api.HandleFunc("/analytics/overview", analyticsHandler.GetOverview).Methods("GET")
 	api.HandleFunc("/analytics/agents", analyticsHandler.GetAgentAnalytics).Methods("GET")
@@ -127,6 +233,11 @@ func main() {
 	api.HandleFunc("/analytics/tokens/timeline", analyticsHandler.GetTokensTimeline).Methods("GET")
 	api.HandleFunc("/analytics/cost/summary", analyticsHandler.GetCostSummary).Methods("GET")
 	api.HandleFunc("/analytics/performance", performanceHandler.GetPerformance).Methods("GET")
+	api.HandleFunc("/analytics/availability", analyticsHandler.GetAvailability).Methods("GET")
+	api.HandleFunc("/analytics/labels", analyticsHandler.GetLabelAnalytics).Methods("GET")
+
+	// Server-Sent Events — the WebSocket stream for clients that can't upgrade
+	api.HandleFunc("/events", hub.ServeSSE).Methods("GET")
 
 	// Global search
 	api.HandleFunc("/search", searchHandler.Search).Methods("GET")
@@ -134,24 +245,25 @@ func main() {
 	// Structure (hierarchy from config)
 	api.HandleFunc("/structure", openclawHandler.GetStructure).Methods("GET")
 
-	// WebSocket
-	router.HandleFunc("/ws/stream", func(w http.ResponseWriter, r *http.Request) {
+	// WebSocket (browsers can't set headers on the upgrade, so ?token= is accepted).
+	// Clients only receive the topics they subscribe to — via ?topics= or a
+	// {"type":"subscribe"} message; "all" opts in to everything. ?after=<seq>
+	// (or {"type":"resume","after":<seq>}) replays what was missed.
+	router.Handle("/ws/stream", auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			log.Printf("WebSocket upgrade error: %v", err)
 			return
 		}
-		client := &websocket.Client{
-			ID:            fmt.Sprintf("client-%d", time.Now().UnixNano()),
-			Hub:           hub,
-			Conn:          conn,
-			Send:          make(chan []byte, 256),
-			Subscriptions: make(map[string]bool),
+		client := websocket.NewClient(hub, conn, fmt.Sprintf("client-%d", time.Now().UnixNano()),
+			websocket.ParseTopics(r.URL.Query().Get("topics")))
+		hub.Register(client)
+		if after, err := strconv.ParseUint(r.URL.Query().Get("after"), 10, 64); err == nil {
+			hub.Resume(client, after)
 		}
-		hub.RegisterClient(client)
 		go client.WritePump()
 		go client.ReadPump()
-	})
+	})))
 
 	// Health check
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -163,12 +275,23 @@ func main() {
 	frontendDir := getEnv("FRONTEND_DIR", "../frontend")
 	router.PathPrefix("/").Handler(http.FileServer(http.Dir(frontendDir)))
 
-	// CORS
+	// CORS — bearer tokens don't need credentialed requests, so credentials
+	// are only allowed for an explicit CORS_ORIGINS list, never for "*".
+	origins := []string{"*"}
+	if v := os.Getenv("CORS_ORIGINS"); v != "" {
+		origins = nil
+		for _, o := range strings.Split(v, ",") {
+			if o = strings.TrimSpace(o); o != "" {
+				origins = append(origins, o)
+			}
+		}
+	}
 	corsHandler := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
-		AllowCredentials: true,
+		ExposedHeaders:   []string{"ETag"},
+		AllowCredentials: len(origins) > 0 && origins[0] != "*",
 	})
 
 	port := getEnv("PORT", "8891")
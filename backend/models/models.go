@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/lib/pq"
@@ -23,17 +24,35 @@ type Task struct {
 	ParentTaskID *string        `json:"parent_task_id,omitempty"`
 	Labels       pq.StringArray `json:"labels,omitempty"`
 	Stuck        bool           `json:"stuck"`
+	Version      int            `json:"version"`
+	LeaseOwner   *string        `json:"lease_owner,omitempty"`
+	LeaseExpires *time.Time     `json:"lease_expires_at,omitempty"`
 }
 
-// TaskHistory represents a single status transition event for a task.
+// TaskHistory is one entry of a task's timeline: either a status transition
+// (Kind "status") or a change to a single field (Kind "field").
 type TaskHistory struct {
-	ID          int        `json:"id"`
-	TaskID      string     `json:"task_id"`
-	FromStatus  *string    `json:"from_status"`
-	ToStatus    string     `json:"to_status"`
-	ChangedBy   *string    `json:"changed_by"`
-	ChangedAt   time.Time  `json:"changed_at"`
-	Note        *string    `json:"note"`
+	Kind       string          `json:"kind"`
+	ID         int             `json:"id"`
+	TaskID     string          `json:"task_id"`
+	FromStatus *string         `json:"from_status"`
+	ToStatus   *string         `json:"to_status"`
+	Field      *string         `json:"field,omitempty"`
+	OldValue   json.RawMessage `json:"old_value,omitempty"`
+	NewValue   json.RawMessage `json:"new_value,omitempty"`
+	ChangedBy  *string         `json:"changed_by"`
+	ChangedAt  time.Time       `json:"changed_at"`
+	Note       *string         `json:"note"`
+}
+
+// TaskDependency is one edge of the blocker graph, joined with the task on
+// the other end of the edge.
+type TaskDependency struct {
+	TaskID    string    `json:"task_id"`
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	CreatedBy *string   `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // Comment represents a comment on a task.
@@ -45,6 +64,79 @@ type Comment struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// APIKey is a stored credential. The token itself is only returned once,
+// when minted; afterwards only its prefix is shown.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	AgentID    *string    `json:"agent_id,omitempty"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	Prefix     string     `json:"prefix"`
+	CreatedBy  *string    `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Webhook is an outbound event subscription. The secret is only returned
+// when the webhook is created.
+type Webhook struct {
+	ID          string         `json:"id"`
+	URL         string         `json:"url"`
+	Secret      string         `json:"secret,omitempty"`
+	Events      pq.StringArray `json:"events"`
+	Description *string        `json:"description,omitempty"`
+	Active      bool           `json:"active"`
+	CreatedBy   *string        `json:"created_by,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// SavedView is a named task filter and sort order. Filters holds the filter
+// definition as stored; see GET /api/views/{id}/tasks.
+type SavedView struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description *string         `json:"description,omitempty"`
+	Filters     json.RawMessage `json:"filters"`
+	Sort        string          `json:"sort"`
+	Shared      bool            `json:"shared"`
+	CreatedBy   string          `json:"created_by"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// Label is a catalog entry for a task label. TaskCount is the number of
+// tasks carrying it; ID is empty for labels in use that aren't registered.
+type Label struct {
+	ID          string     `json:"id,omitempty"`
+	Name        string     `json:"name"`
+	Color       *string    `json:"color,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	Team        *string    `json:"team,omitempty"`
+	TaskCount   int        `json:"task_count"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// WebhookDelivery is one event sent (or to be sent) to a webhook.
+type WebhookDelivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+	ResponseCode  *int            `json:"response_code,omitempty"`
+	LastError     *string         `json:"last_error,omitempty"`
+	RedeliveryOf  *string         `json:"redelivery_of,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+}
+
 // Agent represents an agent record in the DB.
 type Agent struct {
 	ID            string     `json:"id"`
@@ -82,6 +174,15 @@ type AgentMetrics struct {
 	TotalCost                float64   `json:"total_cost"`
 }
 
+// AgentStatusChange is one transition in an agent's status history.
+type AgentStatusChange struct {
+	ID             int64     `json:"id"`
+	AgentID        string    `json:"agent_id"`
+	Status         string    `json:"status"`
+	PreviousStatus *string   `json:"previous_status"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
+
 // DashboardStats represents summary statistics.
 type DashboardStats struct {
 	TotalAgents    int     `json:"total_agents"`
@@ -19,10 +19,30 @@ CREATE TABLE IF NOT EXISTS tasks (
     completed_at TIMESTAMP,
     parent_task_id UUID REFERENCES tasks(id) ON DELETE CASCADE,
     labels TEXT[],
-    CONSTRAINT valid_status CHECK (status IN ('backlog', 'todo', 'next', 'progress', 'review', 'done', 'blocked')),
+    version INT NOT NULL DEFAULT 1,
+    lease_owner VARCHAR(100),
+    lease_expires_at TIMESTAMP,
     CONSTRAINT valid_priority CHECK (priority IN ('low', 'medium', 'high', 'urgent', 'critical', 'moonshot', ''))
 );
 
+-- Statuses are defined per workflow in agents.yaml and validated by the API,
+-- so drop the fixed status constraint from older schemas.
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS valid_status;
+
+-- Add version (optimistic concurrency) if upgrading from older schema
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+
+-- Add claim leases if upgrading from older schema
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS lease_owner VARCHAR(100);
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMP;
+
+-- Full-text search vector for /api/search: title weighted above description
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(description, '')), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_tasks_search ON tasks USING GIN(search);
+
 -- Comments table
 CREATE TABLE IF NOT EXISTS comments (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -32,6 +52,12 @@ CREATE TABLE IF NOT EXISTS comments (
     created_at TIMESTAMP NOT NULL DEFAULT NOW()
 );
 
+-- Full-text search vector for /api/search
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+    to_tsvector('english', content)
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_comments_search ON comments USING GIN(search);
+
 -- Agent Registry table (synced from config, used for relational lookups)
 CREATE TABLE IF NOT EXISTS agents (
     id VARCHAR(100) PRIMARY KEY,
@@ -88,6 +114,17 @@ CREATE TABLE IF NOT EXISTS agent_metrics (
     UNIQUE(agent_id, date)
 );
 
+-- Each session's share of agent_metrics token usage, per day, so a session
+-- file that is rewritten can be recounted without counting it twice.
+CREATE TABLE IF NOT EXISTS agent_session_usage (
+    session_key VARCHAR(255) NOT NULL,
+    date DATE NOT NULL,
+    agent_id VARCHAR(100) NOT NULL,
+    tokens_used BIGINT NOT NULL DEFAULT 0,
+    total_cost DECIMAL(10, 4) NOT NULL DEFAULT 0.0,
+    PRIMARY KEY (session_key, date)
+);
+
 -- Indexes
 CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status);
 CREATE INDEX IF NOT EXISTS idx_tasks_assignee ON tasks(assignee);
@@ -95,6 +132,8 @@ CREATE INDEX IF NOT EXISTS idx_tasks_team ON tasks(team);
 CREATE INDEX IF NOT EXISTS idx_tasks_due_date ON tasks(due_date);
 CREATE INDEX IF NOT EXISTS idx_tasks_created_at ON tasks(created_at);
 CREATE INDEX IF NOT EXISTS idx_tasks_parent ON tasks(parent_task_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_lease_expires ON tasks(lease_expires_at) WHERE lease_owner IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_tasks_labels ON tasks USING GIN(labels);
 
 CREATE INDEX IF NOT EXISTS idx_comments_task ON comments(task_id);
 CREATE INDEX IF NOT EXISTS idx_comments_created ON comments(created_at);
@@ -109,6 +148,153 @@ CREATE INDEX IF NOT EXISTS idx_sessions_started ON agent_sessions(started_at);
 
 CREATE INDEX IF NOT EXISTS idx_metrics_agent_date ON agent_metrics(agent_id, date);
 
+-- JSONL read offsets (how far each background consumer has read each
+-- append-only OpenClaw session file)
+CREATE TABLE IF NOT EXISTS jsonl_offsets (
+    consumer VARCHAR(50) NOT NULL,
+    path TEXT NOT NULL,
+    "offset" BIGINT NOT NULL DEFAULT 0,
+    size BIGINT NOT NULL DEFAULT 0,
+    mtime TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (consumer, path)
+);
+
+-- Token usage index (one row per assistant message with usage, parsed from
+-- session JSONL; backs /api/analytics/tokens and /api/analytics/cost)
+CREATE TABLE IF NOT EXISTS token_messages (
+    id BIGSERIAL PRIMARY KEY,
+    path TEXT NOT NULL,
+    agent_id VARCHAR(100) NOT NULL,
+    ts TIMESTAMP WITH TIME ZONE NOT NULL,
+    model VARCHAR(100),
+    input BIGINT NOT NULL DEFAULT 0,
+    output BIGINT NOT NULL DEFAULT 0,
+    cache_read BIGINT NOT NULL DEFAULT 0,
+    cache_write BIGINT NOT NULL DEFAULT 0,
+    total_tokens BIGINT NOT NULL DEFAULT 0,
+    cost DOUBLE PRECISION NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_token_messages_path ON token_messages(path);
+
+-- API keys (bearer tokens; only the SHA-256 of each token is stored)
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kind VARCHAR(10) NOT NULL,
+    agent_id VARCHAR(100),
+    name VARCHAR(100) NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'member',
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    created_by VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT valid_key_kind CHECK (kind IN ('agent', 'user')),
+    CONSTRAINT agent_key_has_agent CHECK (kind <> 'agent' OR agent_id IS NOT NULL)
+);
+
+-- Outbound webhooks (events matches event types; empty means every event)
+CREATE TABLE IF NOT EXISTS webhooks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    url TEXT NOT NULL,
+    secret VARCHAR(100) NOT NULL,
+    events TEXT[] NOT NULL DEFAULT '{}',
+    description TEXT,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Webhook delivery log; pending rows are the retry queue
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    response_code INT,
+    last_error TEXT,
+    redelivery_of UUID,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT valid_delivery_status CHECK (status IN ('pending', 'succeeded', 'failed'))
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- Saved task views: a named filter (JSON, see handlers/task_filter.go) and
+-- sort order. Unshared views are only listed for their creator.
+CREATE TABLE IF NOT EXISTS saved_views (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    filters JSONB NOT NULL DEFAULT '{}',
+    sort VARCHAR(255) NOT NULL DEFAULT '',
+    shared BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Label catalog. Task labels stay free-form text; this records the canonical
+-- spelling, color and description of each, optionally scoped to one team.
+CREATE TABLE IF NOT EXISTS labels (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL,
+    color VARCHAR(20),
+    description TEXT,
+    team VARCHAR(100),
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_labels_name ON labels(LOWER(name));
+
+-- Broadcast history for WebSocket resume (only written when WS_REPLAY_STORE=postgres)
+CREATE TABLE IF NOT EXISTS ws_events (
+    seq BIGINT PRIMARY KEY,
+    type VARCHAR(100) NOT NULL,
+    topics TEXT[] NOT NULL DEFAULT '{}',
+    data TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Agent status transitions (active / idle / offline), recorded by the status tracker
+CREATE TABLE IF NOT EXISTS agent_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    agent_id VARCHAR(100) NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL,
+    previous_status VARCHAR(20),
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT valid_status_history_status CHECK (status IN ('active', 'idle', 'offline'))
+);
+CREATE INDEX IF NOT EXISTS idx_agent_status_history_agent ON agent_status_history(agent_id, changed_at);
+
+-- Full-text index of session transcripts (one row per message, tool call or
+-- tool result; line_offset and entry_index locate it for the transcript API)
+CREATE TABLE IF NOT EXISTS transcript_entries (
+    id BIGSERIAL PRIMARY KEY,
+    agent_id VARCHAR(100) NOT NULL,
+    session_key VARCHAR(255) NOT NULL,
+    path TEXT NOT NULL,
+    line_offset BIGINT NOT NULL,
+    entry_index INT NOT NULL DEFAULT 0,
+    ts TIMESTAMP WITH TIME ZONE NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    tool_name VARCHAR(100),
+    is_error BOOLEAN NOT NULL DEFAULT FALSE,
+    content TEXT NOT NULL,
+    search tsvector GENERATED ALWAYS AS (to_tsvector('english', content)) STORED,
+    UNIQUE (path, line_offset, entry_index)
+);
+CREATE INDEX IF NOT EXISTS idx_transcript_entries_search ON transcript_entries USING GIN(search);
+CREATE INDEX IF NOT EXISTS idx_transcript_entries_agent_ts ON transcript_entries(agent_id, ts);
+
 -- Task History table (status transition audit trail)
 CREATE TABLE IF NOT EXISTS task_history (
     id SERIAL PRIMARY KEY,
@@ -121,11 +307,53 @@ CREATE TABLE IF NOT EXISTS task_history (
 );
 CREATE INDEX IF NOT EXISTS idx_task_history_task_id ON task_history(task_id);
 
--- Trigger to auto-update updated_at on tasks
+-- Task Field History table (per-field audit trail; status changes live in task_history)
+CREATE TABLE IF NOT EXISTS task_field_history (
+    id SERIAL PRIMARY KEY,
+    task_id UUID NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    field VARCHAR(50) NOT NULL,
+    old_value JSONB,
+    new_value JSONB,
+    changed_by VARCHAR(100),
+    changed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_task_field_history_task_id ON task_field_history(task_id);
+
+-- Task Dependencies table (task_id cannot start until depends_on is done)
+CREATE TABLE IF NOT EXISTS task_dependencies (
+    task_id UUID NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    depends_on UUID NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    created_by VARCHAR(100),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (task_id, depends_on),
+    CONSTRAINT no_self_dependency CHECK (task_id <> depends_on)
+);
+CREATE INDEX IF NOT EXISTS idx_task_dependencies_depends_on ON task_dependencies(depends_on);
+
+-- Task Escalations table (one row per notified level per stuck/overdue episode;
+-- since is the task's updated_at for 'stuck' and its due_date for 'overdue')
+CREATE TABLE IF NOT EXISTS task_escalations (
+    id SERIAL PRIMARY KEY,
+    task_id UUID NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    level INT NOT NULL,
+    since TIMESTAMP NOT NULL,
+    notified VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (task_id, kind, level, since)
+);
+
+-- Trigger to auto-update updated_at on tasks.
+-- Lease renewals alone don't count: a task whose agent heartbeats but makes
+-- no progress must still show up as stuck.
 CREATE OR REPLACE FUNCTION update_updated_at_column()
 RETURNS TRIGGER AS $$
 BEGIN
-    NEW.updated_at = NOW();
+    IF (to_jsonb(NEW) - 'lease_owner' - 'lease_expires_at' - 'updated_at')
+       IS DISTINCT FROM
+       (to_jsonb(OLD) - 'lease_owner' - 'lease_expires_at' - 'updated_at') THEN
+        NEW.updated_at = NOW();
+    END IF;
     RETURN NEW;
 END;
 $$ language 'plpgsql';
@@ -133,3 +361,23 @@ $$ language 'plpgsql';
 DROP TRIGGER IF EXISTS update_tasks_updated_at ON tasks;
 CREATE TRIGGER update_tasks_updated_at BEFORE UPDATE ON tasks
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Trigger to bump tasks.version whenever task content changes.
+-- Bookkeeping-only updates don't count, so they never cause If-Match conflicts.
+CREATE OR REPLACE FUNCTION bump_task_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF ROW(NEW.title, NEW.description, NEW.status, NEW.priority, NEW.assignee, NEW.team,
+           NEW.due_date, NEW.parent_task_id, NEW.labels, NEW.completed_at)
+       IS DISTINCT FROM
+       ROW(OLD.title, OLD.description, OLD.status, OLD.priority, OLD.assignee, OLD.team,
+           OLD.due_date, OLD.parent_task_id, OLD.labels, OLD.completed_at) THEN
+        NEW.version = OLD.version + 1;
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS bump_tasks_version ON tasks;
+CREATE TRIGGER bump_tasks_version BEFORE UPDATE ON tasks
+    FOR EACH ROW EXECUTE FUNCTION bump_task_version();
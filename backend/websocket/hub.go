@@ -3,6 +3,7 @@ package websocket
 import (
 	"encoding/json"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,50 +12,180 @@ import (
 
 // Message represents a WebSocket message.
 type Message struct {
+	Seq       uint64      `json:"seq,omitempty"`
 	Type      string      `json:"type"`
 	Payload   interface{} `json:"payload"`
+	Topics    []string    `json:"topics,omitempty"`
 	Timestamp time.Time   `json:"timestamp"`
 }
 
+// TopicAll subscribes a client to every message.
+const TopicAll = "all"
+
+// Topic builds a topic name such as "task:<id>", "agent:<id>", "team:<name>"
+// or "type:<event>".
+func Topic(kind, id string) string {
+	return kind + ":" + id
+}
+
+// ParseTopics splits a comma-separated topic list, as accepted in ?topics=.
+func ParseTopics(s string) []string {
+	var topics []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
+
 // Client represents a connected WebSocket client.
 type Client struct {
-	ID            string
-	Hub           *Hub
-	Conn          *websocket.Conn
-	Send          chan []byte
-	Subscriptions map[string]bool
-	mu            sync.RWMutex
+	*Subscription
+	Hub  *Hub
+	Conn *websocket.Conn
+}
+
+// NewClient creates a client for conn subscribed to topics.
+func NewClient(hub *Hub, conn *websocket.Conn, id string, topics []string) *Client {
+	return &Client{Subscription: NewSubscription(id, topics), Hub: hub, Conn: conn}
 }
 
-// Hub maintains active clients and broadcasts messages.
+// Options configures the hub's replay buffer.
+type Options struct {
+	// ReplaySize is how many recent messages are kept in memory for resume
+	// (default 1000).
+	ReplaySize int
+	// Store, if set, persists messages so resume can reach further back than
+	// the ring and survives restarts.
+	Store Store
+	// StoreReplay caps how many messages a resume may fetch from the store
+	// (default 10000); larger gaps get resync_required.
+	StoreReplay int
+}
+
+// Hub maintains active subscribers and broadcasts messages.
 type Hub struct {
-	clients    map[*Client]bool
+	clients    map[*Subscription]bool
 	broadcast  chan *Message
-	register   chan *Client
-	unregister chan *Client
+	register   chan *Subscription
+	unregister chan *Subscription
+	resume     chan resumeRequest
+	listeners  []func(*Message)
 	mu         sync.RWMutex
+
+	// Owned by the Run goroutine.
+	seq  uint64
+	ring []entry
+	head int // index of the oldest entry once the ring is full
+
+	replaySize  int
+	store       Store
+	storeReplay int
+	storeQueue  chan entry
+}
+
+// entry is a sequenced message with its encoded form.
+type entry struct {
+	msg  *Message
+	data []byte
+}
+
+type resumeRequest struct {
+	sub   *Subscription
+	after uint64
 }
 
 // NewHub creates a new WebSocket hub.
-func NewHub() *Hub {
-	return &Hub{
-		clients:    make(map[*Client]bool),
-		broadcast:  make(chan *Message, 256),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
+func NewHub(opts Options) *Hub {
+	if opts.ReplaySize <= 0 {
+		opts.ReplaySize = 1000
+	}
+	if opts.StoreReplay <= 0 {
+		opts.StoreReplay = 10000
 	}
+	h := &Hub{
+		clients:     make(map[*Subscription]bool),
+		broadcast:   make(chan *Message, 256),
+		register:    make(chan *Subscription),
+		unregister:  make(chan *Subscription),
+		resume:      make(chan resumeRequest),
+		replaySize:  opts.ReplaySize,
+		store:       opts.Store,
+		storeReplay: opts.StoreReplay,
+	}
+	// With a store, carry on from the last persisted message so a resume
+	// can be served across a restart. Without one (or if it can't be read),
+	// start from the clock so sequence numbers still keep increasing; a
+	// stale resume then gets resync_required instead of someone else's
+	// events.
+	h.seq = uint64(time.Now().UnixMicro())
+	if h.store != nil {
+		if last, err := h.store.LastSeq(); err != nil {
+			log.Printf("[ws] replay store: %v", err)
+		} else {
+			h.seq = last
+		}
+		h.storeQueue = make(chan entry, 4096)
+		go h.persist()
+	}
+	return h
+}
+
+// Register starts delivering messages to a subscriber.
+func (h *Hub) Register(s Subscriber) {
+	h.register <- s.Sub()
+}
+
+// Unregister stops delivery and closes the subscriber's Done channel.
+func (h *Hub) Unregister(s Subscriber) {
+	h.unregister <- s.Sub()
+}
+
+// Resume replays to a subscriber every message after seq that it wants,
+// or sends resync_required if they are no longer available.
+func (h *Hub) Resume(s Subscriber, after uint64) {
+	h.resume <- resumeRequest{s.Sub(), after}
 }
 
-// RegisterClient registers a new client with the hub.
-func (h *Hub) RegisterClient(client *Client) {
-	h.register <- client
+// Listen registers fn to receive every broadcast message, in order, for
+// consumers other than WebSocket clients. fn runs on the hub goroutine and
+// must not block.
+func (h *Hub) Listen(fn func(*Message)) {
+	h.mu.Lock()
+	h.listeners = append(h.listeners, fn)
+	h.mu.Unlock()
+}
+
+// Subscribed reports whether any subscriber has a topic starting with
+// prefix, e.g. "view:", so callers can skip computing topics nobody wants.
+func (h *Hub) Subscribed(prefix string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.clients {
+		if sub.hasTopicPrefix(prefix) {
+			return true
+		}
+	}
+	return false
 }
 
-// Broadcast sends a typed message to all connected clients.
-func (h *Hub) Broadcast(msgType string, payload interface{}) {
+// Broadcast sends a typed message to the clients subscribed to any of its
+// topics. Every message also carries "type:<msgType>"; clients subscribed
+// to "all" receive everything.
+func (h *Hub) Broadcast(msgType string, payload interface{}, topics ...string) {
+	all := []string{Topic("type", msgType)}
+	seen := map[string]bool{all[0]: true}
+	for _, t := range topics {
+		if t != "" && !strings.HasSuffix(t, ":") && !seen[t] {
+			seen[t] = true
+			all = append(all, t)
+		}
+	}
 	message := &Message{
 		Type:      msgType,
 		Payload:   payload,
+		Topics:    all,
 		Timestamp: time.Now(),
 	}
 	select {
@@ -70,33 +201,44 @@ func (h *Hub) Broadcast(msgType string, payload interface{}) {
 func (h *Hub) Run() {
 	for {
 		select {
-		case client := <-h.register:
+		case sub := <-h.register:
 			h.mu.Lock()
-			h.clients[client] = true
+			h.clients[sub] = true
 			h.mu.Unlock()
-			log.Printf("WS client registered: %s", client.ID)
+			sub.enqueue(h.control("hello", h.bounds()))
+			log.Printf("Subscriber registered: %s", sub.ID)
 
-		case client := <-h.unregister:
+		case sub := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.Send)
-				log.Printf("WS client unregistered: %s", client.ID)
+			if _, ok := h.clients[sub]; ok {
+				delete(h.clients, sub)
+				close(sub.done)
+				log.Printf("Subscriber unregistered: %s", sub.ID)
 			}
 			h.mu.Unlock()
 
+		case req := <-h.resume:
+			h.replay(req.sub, req.after)
+
 		case message := <-h.broadcast:
+			h.seq++
+			message.Seq = h.seq
 			data, err := json.Marshal(message)
 			if err != nil {
 				log.Printf("Error marshaling message: %v", err)
 				continue
 			}
+			e := entry{message, data}
+			h.remember(e)
+
+			frame := Frame{Seq: message.Seq, Type: message.Type, Data: data}
 			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.Send <- data:
-				default:
-					go func(c *Client) { h.unregister <- c }(client)
+			for _, fn := range h.listeners {
+				fn(message)
+			}
+			for sub := range h.clients {
+				if sub.Wants(message) {
+					sub.deliver(frame, h.replaySize, h.tooSlow)
 				}
 			}
 			h.mu.RUnlock()
@@ -104,10 +246,163 @@ func (h *Hub) Run() {
 	}
 }
 
+// remember adds e to the replay ring and queues it for the store.
+func (h *Hub) remember(e entry) {
+	if len(h.ring) < h.replaySize {
+		h.ring = append(h.ring, e)
+	} else {
+		h.ring[h.head] = e
+		h.head = (h.head + 1) % h.replaySize
+	}
+	if h.storeQueue != nil {
+		select {
+		case h.storeQueue <- e:
+		default:
+			// The gap is detected on resume and answered with resync_required.
+			log.Printf("[ws] replay store queue full, not persisting seq %d", e.msg.Seq)
+		}
+	}
+}
+
+// persist writes messages to the store in sequence order.
+func (h *Hub) persist() {
+	for e := range h.storeQueue {
+		if err := h.store.Append(e.msg, e.data); err != nil {
+			log.Printf("[ws] persist seq %d: %v", e.msg.Seq, err)
+		}
+	}
+}
+
+// oldest returns the oldest ring entry's sequence number, or 0 if empty.
+func (h *Hub) oldest() uint64 {
+	if len(h.ring) == 0 {
+		return 0
+	}
+	return h.ring[h.head].msg.Seq
+}
+
+// bounds describes the replayable range, for hello and resync_required.
+func (h *Hub) bounds() map[string]uint64 {
+	oldest := h.oldest()
+	if oldest == 0 {
+		oldest = h.seq + 1
+	}
+	return map[string]uint64{"latest": h.seq, "oldest": oldest}
+}
+
+// control encodes an unsequenced hub-to-subscriber message.
+func (h *Hub) control(msgType string, payload interface{}) Frame {
+	data, _ := json.Marshal(&Message{Type: msgType, Payload: payload, Timestamp: time.Now()})
+	return Frame{Type: msgType, Data: data}
+}
+
+// tooSlow is the resync_required sent to a subscriber whose queue overflowed.
+func (h *Hub) tooSlow() Frame {
+	b := h.bounds()
+	return h.control("resync_required", map[string]interface{}{
+		"reason": "client too slow", "oldest": b["oldest"], "latest": b["latest"],
+	})
+}
+
+// replay queues every message after seq that the subscriber wants, from
+// the ring and, for older messages, the store. It runs on the hub goroutine,
+// so no live message can slip in between, but never waits on the store:
+// that read happens in its own goroutine while the subscriber's live
+// messages are held back.
+func (h *Hub) replay(c *Subscription, after uint64) {
+	b := h.bounds()
+	resync := func(reason string) Frame {
+		return h.control("resync_required", map[string]interface{}{
+			"reason": reason, "after": after, "oldest": b["oldest"], "latest": b["latest"],
+		})
+	}
+
+	if after > h.seq {
+		c.resumeWith(nil, resync("unknown sequence number"))
+		return
+	}
+
+	var ring []Frame
+	for i := 0; i < len(h.ring); i++ {
+		e := h.ring[(h.head+i)%len(h.ring)]
+		if e.msg.Seq > after && c.Wants(e.msg) {
+			ring = append(ring, Frame{Seq: e.msg.Seq, Type: e.msg.Type, Data: e.data})
+		}
+	}
+	resumed := h.control("resumed", map[string]uint64{"after": after, "latest": h.seq})
+
+	before := h.oldest()
+	if before == 0 {
+		before = h.seq + 1
+	}
+	if after+1 >= before {
+		c.resumeWith(ring, resumed)
+		return
+	}
+	if h.store == nil || before-after-1 > uint64(h.storeReplay) {
+		c.resumeWith(nil, resync("gap too large"))
+		return
+	}
+
+	gen := c.hold()
+	unavailable, gap := resync("replay unavailable"), resync("gap too large")
+	go func() {
+		stored, err := h.store.Range(after, before)
+		if err != nil {
+			log.Printf("[ws] replay from store: %v", err)
+			c.finishResume(gen, nil, unavailable)
+			return
+		}
+		if !contiguous(stored, after, before) {
+			c.finishResume(gen, nil, gap)
+			return
+		}
+		var backlog []Frame
+		for _, s := range stored {
+			if c.wantsTopics(s.Topics) {
+				backlog = append(backlog, Frame{Seq: s.Seq, Type: s.Type, Data: s.Data})
+			}
+		}
+		c.finishResume(gen, append(backlog, ring...), resumed)
+	}()
+}
+
+// contiguous reports whether stored holds every message with
+// after < seq < before, with none missing.
+func contiguous(stored []StoredMessage, after, before uint64) bool {
+	next := after + 1
+	for _, s := range stored {
+		if s.Seq != next {
+			return false
+		}
+		next++
+	}
+	return next == before
+}
+
+// topicsFrom reads the topics of a subscribe/unsubscribe message: "topics"
+// (a list), "topic", or the legacy "id".
+func topicsFrom(msg map[string]interface{}) []string {
+	var topics []string
+	if list, ok := msg["topics"].([]interface{}); ok {
+		for _, v := range list {
+			if t, ok := v.(string); ok && t != "" {
+				topics = append(topics, t)
+			}
+		}
+	}
+	for _, key := range []string{"topic", "id"} {
+		if t, ok := msg[key].(string); ok && t != "" {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
+
 // ReadPump handles reading messages from the client.
 func (c *Client) ReadPump() {
 	defer func() {
-		c.Hub.unregister <- c
+		c.Hub.Unregister(c)
 		c.Conn.Close()
 	}()
 
@@ -129,23 +424,19 @@ func (c *Client) ReadPump() {
 		if msgType, ok := msg["type"].(string); ok {
 			switch msgType {
 			case "subscribe":
-				if id, ok := msg["id"].(string); ok {
-					c.mu.Lock()
-					c.Subscriptions[id] = true
-					c.mu.Unlock()
-				}
+				c.Subscribe(topicsFrom(msg)...)
 			case "unsubscribe":
-				if id, ok := msg["id"].(string); ok {
-					c.mu.Lock()
-					delete(c.Subscriptions, id)
-					c.mu.Unlock()
+				c.Unsubscribe(topicsFrom(msg)...)
+			case "resume":
+				if after, ok := msg["after"].(float64); ok && after >= 0 {
+					c.Hub.Resume(c, uint64(after))
 				}
 			}
 		}
 	}
 }
 
-// WritePump handles writing messages to the client.
+// WritePump handles writing messages to the client, one frame per message.
 func (c *Client) WritePump() {
 	ticker := time.NewTicker(54 * time.Second)
 	defer func() {
@@ -155,24 +446,16 @@ func (c *Client) WritePump() {
 
 	for {
 		select {
-		case message, ok := <-c.Send:
+		case <-c.Done():
 			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if !ok {
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-			w, err := c.Conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-			n := len(c.Send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.Send)
-			}
-			if err := w.Close(); err != nil {
-				return
+			c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+		case <-c.Ready():
+			for _, f := range c.Take() {
+				c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+				if err := c.Conn.WriteMessage(websocket.TextMessage, f.Data); err != nil {
+					return
+				}
 			}
 		case <-ticker.C:
 			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// sseKeepalive is how often an idle SSE stream gets a comment line, so
+// proxies don't time it out.
+const sseKeepalive = 15 * time.Second
+
+// ServeSSE streams hub messages as Server-Sent Events, for clients that
+// can't use WebSockets. Each event has the message's seq as its id, its
+// type as the event name, and the same JSON envelope as data. Topics come
+// from ?topics= as for /ws/stream; Last-Event-ID (or ?after=) resumes.
+//
+// SSE is one-way, so a stream that falls too far behind gets
+// resync_required and is closed; the client's reconnect resumes from its
+// Last-Event-ID.
+func (h *Hub) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	topics := ParseTopics(r.URL.Query().Get("topics"))
+	if len(topics) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "topics is required (topics=all for every event)"})
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The server's WriteTimeout would otherwise cut every stream short.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	sub := NewSubscription(fmt.Sprintf("sse-%d", time.Now().UnixNano()), topics)
+	h.Register(sub)
+	defer h.Unregister(sub)
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("after")
+	}
+	if after, err := strconv.ParseUint(lastID, 10, 64); err == nil {
+		h.Resume(sub, after)
+	}
+
+	keepalive := time.NewTicker(sseKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Done():
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-sub.Ready():
+			for _, f := range sub.Take() {
+				if f.Seq > 0 {
+					fmt.Fprintf(w, "id: %d\n", f.Seq)
+				}
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", f.Type, f.Data); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+			if sub.Lagging() {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package websocket
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// StoredMessage is a persisted broadcast, as needed for replay.
+type StoredMessage struct {
+	Seq    uint64
+	Type   string
+	Topics []string
+	Data   []byte
+}
+
+// Store persists broadcast messages for resume beyond the in-memory ring.
+type Store interface {
+	// Append saves a message. Calls arrive in sequence order.
+	Append(m *Message, data []byte) error
+	// Range returns the stored messages with after < seq < before, oldest
+	// first. Missing sequence numbers are simply absent.
+	Range(after, before uint64) ([]StoredMessage, error)
+	// LastSeq returns the highest stored sequence number, or 0.
+	LastSeq() (uint64, error)
+}
+
+// PostgresStore keeps the most recent messages in the ws_events table.
+type PostgresStore struct {
+	db      *sql.DB
+	retain  int
+	appends int
+}
+
+// NewPostgresStore returns a store that keeps the latest retain messages.
+func NewPostgresStore(db *sql.DB, retain int) *PostgresStore {
+	if retain <= 0 {
+		retain = 10000
+	}
+	return &PostgresStore{db: db, retain: retain}
+}
+
+// Append implements Store. Old rows are trimmed every few hundred appends.
+func (s *PostgresStore) Append(m *Message, data []byte) error {
+	if _, err := s.db.Exec(`
+		INSERT INTO ws_events (seq, type, topics, data) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (seq) DO NOTHING`,
+		int64(m.Seq), m.Type, pq.Array(m.Topics), string(data)); err != nil {
+		return err
+	}
+	s.appends++
+	if s.appends%200 == 0 {
+		_, err := s.db.Exec(`DELETE FROM ws_events WHERE seq <= $1`, int64(m.Seq)-int64(s.retain))
+		return err
+	}
+	return nil
+}
+
+// Range implements Store.
+func (s *PostgresStore) Range(after, before uint64) ([]StoredMessage, error) {
+	rows, err := s.db.Query(`
+		SELECT seq, type, topics, data FROM ws_events
+		WHERE seq > $1 AND seq < $2
+		ORDER BY seq`, int64(after), int64(before))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StoredMessage
+	for rows.Next() {
+		var seq int64
+		var msgType, data string
+		var topics pq.StringArray
+		if err := rows.Scan(&seq, &msgType, &topics, &data); err != nil {
+			return nil, err
+		}
+		out = append(out, StoredMessage{Seq: uint64(seq), Type: msgType, Topics: topics, Data: []byte(data)})
+	}
+	return out, rows.Err()
+}
+
+// LastSeq implements Store.
+func (s *PostgresStore) LastSeq() (uint64, error) {
+	var seq sql.NullInt64
+	if err := s.db.QueryRow(`SELECT MAX(seq) FROM ws_events`).Scan(&seq); err != nil {
+		return 0, err
+	}
+	return uint64(seq.Int64), nil
+}
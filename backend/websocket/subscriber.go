@@ -0,0 +1,218 @@
+package websocket
+
+import (
+	"strings"
+	"sync"
+)
+
+// Frame is one queued message for a subscriber. Control messages (hello,
+// resumed, resync_required) have no sequence number.
+type Frame struct {
+	Seq  uint64
+	Type string
+	Data []byte // the JSON Message envelope
+}
+
+// Subscriber is anything the hub delivers messages to. WebSocket clients
+// and SSE streams both embed a Subscription to implement it.
+type Subscriber interface {
+	Sub() *Subscription
+}
+
+// Subscription holds a subscriber's topics and its outbound queue, which
+// the transport drains when Ready fires. A subscriber that falls too far
+// behind is not dropped: its queue is replaced by resync_required and live
+// messages are held back until it resumes.
+type Subscription struct {
+	ID string
+
+	mu     sync.RWMutex
+	topics map[string]bool
+
+	qmu     sync.Mutex
+	queue   []Frame
+	lagging bool
+	holding bool // a resume is reading the store; live messages wait in held
+	held    []Frame
+	holdGen int
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+// NewSubscription creates a subscription to topics.
+func NewSubscription(id string, topics []string) *Subscription {
+	s := &Subscription{
+		ID:     id,
+		topics: make(map[string]bool),
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	s.Subscribe(topics...)
+	return s
+}
+
+// Sub implements Subscriber.
+func (s *Subscription) Sub() *Subscription {
+	return s
+}
+
+// Subscribe adds topics.
+func (s *Subscription) Subscribe(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range topics {
+		s.topics[t] = true
+	}
+}
+
+// Unsubscribe removes topics.
+func (s *Subscription) Unsubscribe(topics ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range topics {
+		delete(s.topics, t)
+	}
+}
+
+// Wants reports whether the subscriber is subscribed to the message.
+func (s *Subscription) Wants(m *Message) bool {
+	return s.wantsTopics(m.Topics)
+}
+
+func (s *Subscription) hasTopicPrefix(prefix string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for t := range s.topics {
+		if strings.HasPrefix(t, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Subscription) wantsTopics(topics []string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.topics[TopicAll] {
+		return true
+	}
+	for _, t := range topics {
+		if s.topics[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// Ready fires when frames are waiting to be taken.
+func (s *Subscription) Ready() <-chan struct{} {
+	return s.wake
+}
+
+// Done is closed when the hub unregisters the subscriber.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Take empties the queue.
+func (s *Subscription) Take() []Frame {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+	q := s.queue
+	s.queue = nil
+	return q
+}
+
+// Lagging reports whether live messages are held back pending a resume.
+func (s *Subscription) Lagging() bool {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+	return s.lagging
+}
+
+// deliver queues a live message. Past limit queued frames the subscriber
+// is switched to lagging and its queue replaced with resync.
+func (s *Subscription) deliver(f Frame, limit int, resync func() Frame) {
+	s.qmu.Lock()
+	if s.lagging {
+		s.qmu.Unlock()
+		return
+	}
+	if s.holding {
+		if len(s.held) < limit {
+			s.held = append(s.held, f)
+			s.qmu.Unlock()
+			return
+		}
+		// Too much arrived while the replay was read; give up on it.
+		s.holding, s.held = false, nil
+		s.lagging = true
+		s.queue = []Frame{resync()}
+	} else if len(s.queue) >= limit {
+		s.lagging = true
+		s.queue = []Frame{resync()}
+	} else {
+		s.queue = append(s.queue, f)
+	}
+	s.qmu.Unlock()
+	s.notify()
+}
+
+// enqueue queues a frame regardless of lag state.
+func (s *Subscription) enqueue(f Frame) {
+	s.qmu.Lock()
+	s.queue = append(s.queue, f)
+	s.qmu.Unlock()
+	s.notify()
+}
+
+// resumeWith queues a replayed backlog followed by a marker ("resumed" or
+// resync_required), and lets live messages through again. Live messages
+// queued while lagging were dropped, so the backlog covers them.
+func (s *Subscription) resumeWith(backlog []Frame, marker Frame) {
+	s.qmu.Lock()
+	if s.lagging {
+		s.queue = nil
+	}
+	s.queue = append(append(s.queue, backlog...), marker)
+	// A backlog from the hub covers anything held for an earlier resume.
+	s.lagging, s.holding, s.held = false, false, nil
+	s.qmu.Unlock()
+	s.notify()
+}
+
+// hold starts a resume whose backlog is read off the hub goroutine: live
+// messages are kept back, in order, until finishResume. It returns the
+// token finishResume needs.
+func (s *Subscription) hold() int {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+	if s.lagging {
+		s.queue = nil
+	}
+	s.lagging, s.holding, s.held = false, true, nil
+	s.holdGen++
+	return s.holdGen
+}
+
+// finishResume queues the backlog and marker of a held resume, then the
+// live messages that arrived meanwhile. It does nothing if the hold was
+// superseded or overflowed into resync_required.
+func (s *Subscription) finishResume(gen int, backlog []Frame, marker Frame) {
+	s.qmu.Lock()
+	if !s.holding || gen != s.holdGen {
+		s.qmu.Unlock()
+		return
+	}
+	s.queue = append(append(append(s.queue, backlog...), marker), s.held...)
+	s.holding, s.held = false, nil
+	s.qmu.Unlock()
+	s.notify()
+}
+
+func (s *Subscription) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
@@ -2,8 +2,24 @@
 
 const API_BASE = window.AGENTBOARD_API || '';
 
+// API token (when the server runs with AUTH_ENABLED=true)
+window.getApiToken = () => localStorage.getItem('agentboard_token') || '';
+
+function withAuth(options) {
+  const token = window.getApiToken();
+  if (!token) return options;
+  return { ...options, headers: { ...(options.headers || {}), Authorization: `Bearer ${token}` } };
+}
+
 window.apiFetch = async function apiFetch(path, options = {}) {
-  const res = await fetch(API_BASE + path, options);
+  let res = await fetch(API_BASE + path, withAuth(options));
+  if (res.status === 401) {
+    const token = prompt('AgentBoard API token');
+    if (token) {
+      localStorage.setItem('agentboard_token', token.trim());
+      res = await fetch(API_BASE + path, withAuth(options));
+    }
+  }
   if (!res.ok) {
     const text = await res.text().catch(() => '');
     throw new Error(`API ${path} → ${res.status}: ${text}`);
@@ -32,23 +48,125 @@ window.API = {
     headers: { 'Content-Type': 'application/json' },
     body: JSON.stringify(data)
   }),
+  patchTask: (id, patch) => apiFetch(`/api/tasks/${id}`, {
+    method: 'PATCH',
+    headers: { 'Content-Type': 'application/merge-patch+json' },
+    body: JSON.stringify(patch)
+  }),
   transitionTask: (id, status) => apiFetch(`/api/tasks/${id}/transition`, {
     method: 'POST',
     headers: { 'Content-Type': 'application/json' },
     body: JSON.stringify({ status })
   }),
+  assignTask: (id, assignee) => apiFetch(`/api/tasks/${id}/assign`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(assignee === 'auto' ? { auto: true } : { assignee })
+  }),
   getActivity: (params = {}) => {
     const qs = new URLSearchParams(params).toString();
     return apiFetch('/api/activity' + (qs ? '?' + qs : ''));
   },
   getAgentSoul: (id) => apiFetch(`/api/agents/${id}/soul`),
   getAgentSkills: (id) => apiFetch(`/api/agents/${id}/skills`),
+  getAgentSessions: (id, params = {}) => {
+    const qs = new URLSearchParams(params).toString();
+    return apiFetch(`/api/agents/${id}/sessions` + (qs ? '?' + qs : ''));
+  },
+  getSessionTranscript: (id, sessionKey, params = {}) => {
+    const qs = new URLSearchParams(params).toString();
+    return apiFetch(`/api/agents/${id}/sessions/${encodeURIComponent(sessionKey)}/transcript` + (qs ? '?' + qs : ''));
+  },
+  getAgentStatusHistory: (id, params = {}) => {
+    const qs = new URLSearchParams(params).toString();
+    return apiFetch(`/api/agents/${id}/status-history` + (qs ? '?' + qs : ''));
+  },
   getStreamFiltered: (agentId, limit = 50) => apiFetch(`/api/openclaw/stream?agent_id=${encodeURIComponent(agentId)}&limit=${limit}`),
   getDashboardStats: () => apiFetch('/api/dashboard/stats'),
 
+  // Workflows
+  getWorkflows: (team) => apiFetch('/api/workflows' + (team ? `?team=${encodeURIComponent(team)}` : '')),
+
   // Branding
   getBranding: () => apiFetch('/api/branding'),
 
+  // API keys
+  getKeys: () => apiFetch('/api/keys'),
+  createKey: (data) => apiFetch('/api/keys', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(data)
+  }),
+  revokeKey: (id) => apiFetch(`/api/keys/${id}`, { method: 'DELETE' }),
+  getMe: () => apiFetch('/api/auth/me'),
+
+  // Webhooks
+  getWebhooks: () => apiFetch('/api/webhooks'),
+  createWebhook: (data) => apiFetch('/api/webhooks', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(data)
+  }),
+  updateWebhook: (id, data) => apiFetch(`/api/webhooks/${id}`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(data)
+  }),
+  deleteWebhook: (id) => apiFetch(`/api/webhooks/${id}`, { method: 'DELETE' }),
+  getWebhookDeliveries: (id, params = {}) => {
+    const qs = new URLSearchParams(params).toString();
+    return apiFetch(`/api/webhooks/${id}/deliveries` + (qs ? '?' + qs : ''));
+  },
+  redeliverWebhook: (id, deliveryId) => apiFetch(`/api/webhooks/${id}/deliveries/${deliveryId}/redeliver`, { method: 'POST' }),
+
+  // Saved views
+  getViews: () => apiFetch('/api/views'),
+  getView: (id) => apiFetch(`/api/views/${id}`),
+  createView: (data) => apiFetch('/api/views', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(data)
+  }),
+  updateView: (id, data) => apiFetch(`/api/views/${id}`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(data)
+  }),
+  deleteView: (id) => apiFetch(`/api/views/${id}`, { method: 'DELETE' }),
+  getViewTasks: (id, params = {}) => {
+    const qs = new URLSearchParams(params).toString();
+    return apiFetch(`/api/views/${id}/tasks` + (qs ? '?' + qs : ''));
+  },
+
+  // Labels
+  getLabels: (params = {}) => {
+    const qs = new URLSearchParams(params).toString();
+    return apiFetch('/api/labels' + (qs ? '?' + qs : ''));
+  },
+  createLabel: (data) => apiFetch('/api/labels', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(data)
+  }),
+  updateLabel: (id, data) => apiFetch(`/api/labels/${encodeURIComponent(id)}`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(data)
+  }),
+  deleteLabel: (id) => apiFetch(`/api/labels/${encodeURIComponent(id)}`, { method: 'DELETE' }),
+  mergeLabels: (sources, into) => apiFetch('/api/labels/merge', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ sources, into })
+  }),
+
+  // Pricing & budgets
+  getPricing: () => apiFetch('/api/pricing'),
+  getBudgets: (params = {}) => {
+    const qs = new URLSearchParams(params).toString();
+    return apiFetch('/api/budgets' + (qs ? '?' + qs : ''));
+  },
+
   // Comments
   getComments: (taskId) => apiFetch(`/api/tasks/${taskId}/comments`),
   addComment: (taskId, text) => apiFetch(`/api/tasks/${taskId}/comments`, {
@@ -63,8 +181,20 @@ window.API = {
   // Task history
   getTaskHistory: (id) => apiFetch(`/api/tasks/${id}/history`),
 
+  // Task dependencies
+  getDependencies: (id) => apiFetch(`/api/tasks/${id}/dependencies`),
+  addDependency: (id, dependsOn) => apiFetch(`/api/tasks/${id}/dependencies`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ depends_on: dependsOn })
+  }),
+  removeDependency: (id, dependsOn) => apiFetch(`/api/tasks/${id}/dependencies/${dependsOn}`, { method: 'DELETE' }),
+
   // Search
-  search: (q, limit = 20) => apiFetch(`/api/search?q=${encodeURIComponent(q)}&limit=${limit}`),
+  search: (q, limit = 20, params = {}) => {
+    const qs = new URLSearchParams({ q, limit, ...params }).toString();
+    return apiFetch(`/api/search?${qs}`);
+  },
 
   // Analytics
   getAnalyticsThroughput: (params = {}) => {
@@ -76,6 +206,14 @@ window.API = {
     return apiFetch('/api/analytics/agents' + (qs ? '?' + qs : ''));
   },
   getPerformance: () => apiFetch('/api/analytics/performance'),
+  getAvailability: (params = {}) => {
+    const qs = new URLSearchParams(params).toString();
+    return apiFetch('/api/analytics/availability' + (qs ? '?' + qs : ''));
+  },
+  getLabelAnalytics: (params = {}) => {
+    const qs = new URLSearchParams(params).toString();
+    return apiFetch('/api/analytics/labels' + (qs ? '?' + qs : ''));
+  },
 
   exportCSV: () => {
     const a = document.createElement('a');
@@ -7,6 +7,10 @@
     + '/ws/stream';
 
   const listeners = {};
+  // Topics this dashboard follows; re-sent on every reconnect.
+  const topics = new Set(['all']);
+  // Sequence number of the last event seen, for resuming after a reconnect.
+  let lastSeq = 0;
   let ws = null;
   let reconnectTimeout = null;
   let reconnectDelay = 2000;
@@ -16,7 +20,11 @@
     if (ws && (ws.readyState === WebSocket.OPEN || ws.readyState === WebSocket.CONNECTING)) return;
 
     try {
-      ws = new WebSocket(WS_URL);
+      const token = window.getApiToken ? window.getApiToken() : '';
+      const params = new URLSearchParams({ topics: [...topics].join(',') });
+      if (lastSeq) params.set('after', lastSeq);
+      if (token) params.set('token', token);
+      ws = new WebSocket(`${WS_URL}?${params}`);
     } catch (e) {
       scheduleReconnect();
       return;
@@ -33,6 +41,17 @@
       try {
         const msg = JSON.parse(event.data);
         const type = msg.type || 'message';
+        if (msg.seq) lastSeq = msg.seq;
+        if (type === 'resync_required') {
+          // Too slow to keep up: ask for the backlog. Otherwise the gap can't
+          // be replayed and pages should reload their data.
+          if (msg.payload && msg.payload.reason === 'client too slow' && lastSeq) {
+            send({ type: 'resume', after: lastSeq });
+          } else {
+            emit('_resync', msg.payload || {});
+          }
+          return;
+        }
         emit(type, msg.data || msg);
         emit('_any', msg);
       } catch (e) {
@@ -76,10 +95,25 @@
     });
   }
 
+  function send(msg) {
+    if (ws && ws.readyState === WebSocket.OPEN) ws.send(JSON.stringify(msg));
+  }
+
+  // Topics: "all", "task:<id>", "agent:<id>", "team:<name>", "type:<event>".
+  function subscribe(...list) {
+    list.forEach(t => topics.add(t));
+    send({ type: 'subscribe', topics: list });
+  }
+
+  function unsubscribe(...list) {
+    list.forEach(t => topics.delete(t));
+    send({ type: 'unsubscribe', topics: list });
+  }
+
   function isConnected() { return connected; }
 
   // Start connection
   connect();
 
-  window.WS = { on, off, isConnected };
+  window.WS = { on, off, subscribe, unsubscribe, isConnected };
 })();
@@ -37,9 +37,10 @@ Pages.activity = {
     await this._renderFeed(document.getElementById('activityFeedContent'), null);
 
     // WS for live events
-    const streamHandler = (data) => {
-      this._onLiveEvent(data);
+    const streamHandler = (msg) => {
+      this._onStreamEntry(msg.payload || {});
     };
+    const rateHandler = () => this._countEvent();
     const connHandler = () => {
       document.getElementById('liveDot')?.classList.remove('live-indicator__dot--error');
       document.getElementById('liveLabel')?.classList.remove('live-indicator__label--error');
@@ -52,10 +53,11 @@ Pages.activity = {
       if (document.getElementById('liveRate')) document.getElementById('liveRate').textContent = '';
     };
 
-    WS.on('_any', streamHandler);
+    WS.on('stream_entry', streamHandler);
+    WS.on('_any', rateHandler);
     WS.on('_connected', connHandler);
     WS.on('_disconnected', disconnHandler);
-    this._wsHandlers.push(['_any', streamHandler], ['_connected', connHandler], ['_disconnected', disconnHandler]);
+    this._wsHandlers.push(['stream_entry', streamHandler], ['_any', rateHandler], ['_connected', connHandler], ['_disconnected', disconnHandler]);
 
     if (WS.isConnected()) connHandler();
     else disconnHandler();
@@ -200,7 +202,7 @@ Pages.activity = {
     }
   },
 
-  _onLiveEvent(data) {
+  _countEvent() {
     // Track event rate
     this._lastMinuteEvents.push(Date.now());
     this._lastMinuteEvents = this._lastMinuteEvents.filter(t => Date.now() - t < 60000);
@@ -208,23 +210,27 @@ Pages.activity = {
     const rate = this._lastMinuteEvents.length;
     const rateEl = document.getElementById('liveRate');
     if (rateEl) rateEl.textContent = `${rate} event${rate !== 1 ? 's' : ''}/min`;
+  },
 
-    // Live prepend if global mode
-    if (this._mode === 'global' && data.type && data.type !== '_connected' && data.type !== '_disconnected') {
-      const feedEl = document.getElementById('activityFeedContent');
-      if (!feedEl || feedEl.querySelector('.loading-state')) return;
-
-      const item = this._streamToItem(data);
-      const html = this._itemHTML(item);
-      const tmp = document.createElement('div');
-      tmp.innerHTML = html;
-      const node = tmp.firstElementChild;
-      if (node) {
-        feedEl.insertBefore(node, feedEl.firstChild);
-        // Keep max 100 items
-        const items = feedEl.querySelectorAll('.activity-item');
-        if (items.length > 100) items[items.length - 1].remove();
-      }
+  _onStreamEntry(entry) {
+    // Live prepend, for the selected agent only in per-agent mode
+    if (this._mode === 'agent') {
+      if (!this._selectedAgent || (entry.agentId !== this._selectedAgent && entry.agent !== this._selectedAgent)) return;
+    }
+    const feedEl = document.getElementById('activityFeedContent');
+    if (!feedEl || feedEl.querySelector('.loading-state')) return;
+    feedEl.querySelector('.empty-state')?.remove();
+
+    const item = this._streamToItem(entry);
+    const html = this._itemHTML(item);
+    const tmp = document.createElement('div');
+    tmp.innerHTML = html;
+    const node = tmp.firstElementChild;
+    if (node) {
+      feedEl.insertBefore(node, feedEl.firstChild);
+      // Keep max 100 items
+      const items = feedEl.querySelectorAll('.activity-item');
+      if (items.length > 100) items[items.length - 1].remove();
     }
   },
 
@@ -45,8 +45,8 @@ Pages.agents = {
         this._paintGrid();
       }
     };
-    WS.on('agent_status_update', handler);
-    this._wsHandlers.push(['agent_status_update', handler]);
+    WS.on('agent_status_changed', handler);
+    this._wsHandlers.push(['agent_status_changed', handler]);
 
     this._refreshTimer = setInterval(async () => {
       try {
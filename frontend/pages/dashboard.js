@@ -36,8 +36,8 @@ Pages.dashboard = {
 
     // Real-time update via WS
     const handler = () => this._load();
-    WS.on('agent_status_update', handler);
-    this._wsHandlers.push(['agent_status_update', handler]);
+    WS.on('agent_status_changed', handler);
+    this._wsHandlers.push(['agent_status_changed', handler]);
 
     // Auto-refresh every 30s
     this._refreshTimer = setInterval(() => this._load(), 30000);
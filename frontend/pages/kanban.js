@@ -134,7 +134,8 @@ Pages.kanban = {
     WS.on('task_updated', taskHandler);
     WS.on('task_created', taskHandler);
     WS.on('task_deleted', taskHandler);
-    this._wsHandlers.push(['task_updated', taskHandler], ['task_created', taskHandler], ['task_deleted', taskHandler]);
+    WS.on('_resync', taskHandler);
+    this._wsHandlers.push(['task_updated', taskHandler], ['task_created', taskHandler], ['task_deleted', taskHandler], ['_resync', taskHandler]);
   },
 
   async _loadAll() {
@@ -440,8 +441,19 @@ Pages.kanban = {
       dot: '#6B7280',
     });
 
-    // Transition entries
+    // Transition and field-change entries
     (history || []).forEach(h => {
+      if (h.kind === 'field') {
+        const who = h.changed_by ? ` <span style="color:var(--text-tertiary)">(${Utils.esc(h.changed_by)})</span>` : '';
+        const fmt = (v) => v === null || v === undefined ? '—' : (Array.isArray(v) ? v.join(', ') || '—' : String(v));
+        items.push({
+          labelHTML: `<span style="color:var(--text-secondary)">${Utils.esc(h.field)}</span>: `
+            + `${Utils.esc(fmt(h.old_value))} → ${Utils.esc(fmt(h.new_value))}${who}`,
+          time: h.changed_at,
+          dot: '#6B7280',
+        });
+        return;
+      }
       const fromColor = this._statusColor(h.from_status);
       const toColor = this._statusColor(h.to_status);
       const who = h.changed_by ? ` <span style="color:var(--text-tertiary)">(${Utils.esc(h.changed_by)})</span>` : '';
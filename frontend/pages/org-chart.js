@@ -58,8 +58,8 @@ Pages.orgChart = {
         this._updateNodeStatuses();
       }
     };
-    WS.on('agent_status_update', handler);
-    this._wsHandlers.push(['agent_status_update', handler]);
+    WS.on('agent_status_changed', handler);
+    this._wsHandlers.push(['agent_status_changed', handler]);
 
     this._refreshTimer = setInterval(async () => {
       try { this._agents = await API.getAgents(); this._updateNodeStatuses(); } catch (_) {}
@@ -127,7 +127,7 @@ window.Search = (function () {
     _selectedIdx = -1;
     _results.innerHTML = `
       <div style="padding:40px 20px;text-align:center;color:var(--text-muted);font-size:14px;">
-        Type to search tasks, agents, and comments…
+        Type to search tasks, agents, comments, and transcripts…
       </div>`;
   }
 
@@ -163,8 +163,9 @@ window.Search = (function () {
     const tasks    = (data.tasks    || []).slice(0, 10);
     const agents   = (data.agents   || []).slice(0, 6);
     const comments = (data.comments || []).slice(0, 6);
+    const transcripts = (data.transcripts || []).slice(0, 6);
 
-    const total = tasks.length + agents.length + comments.length;
+    const total = tasks.length + agents.length + comments.length + transcripts.length;
     if (total === 0) { _renderNoResults(); return; }
 
     _items = [];
@@ -237,7 +238,6 @@ window.Search = (function () {
       comments.forEach(c => {
         const idx = _items.length;
         _items.push({ action: () => _openComment(c) });
-        const excerpt = (c.content || c.text || '').substring(0, 120);
         const taskTitle = c.task_title || c.taskTitle || '';
 
         html += `<div class="search-item" data-idx="${idx}" onclick="Search._clickItem(${idx})" style="
@@ -249,7 +249,7 @@ window.Search = (function () {
           <span style="font-size:16px;margin-top:1px;">💬</span>
           <div style="flex:1;min-width:0;">
             <div style="font-size:13px;color:var(--text-primary);line-height:1.4;word-break:break-word;">
-              ${_highlight(Utils.esc(excerpt), q)}${excerpt.length >= 120 ? '…' : ''}
+              ${_snippet(c, q)}
             </div>
             ${taskTitle ? `<div style="font-size:11px;color:var(--text-muted);margin-top:2px;">on: ${Utils.esc(taskTitle)}</div>` : ''}
           </div>
@@ -257,6 +257,32 @@ window.Search = (function () {
       });
     }
 
+    /* ── Transcripts section ── */
+    if (transcripts.length > 0) {
+      html += _sectionHeader('Transcripts', transcripts.length);
+      transcripts.forEach(tr => {
+        const idx = _items.length;
+        _items.push({ action: () => _openAgent(tr) });
+        const meta = tr.meta || {};
+        const when = meta.timestamp ? new Date(meta.timestamp).toLocaleString() : '';
+
+        html += `<div class="search-item" data-idx="${idx}" onclick="Search._clickItem(${idx})" style="
+          display:flex;align-items:flex-start;gap:10px;
+          padding:10px 16px;cursor:pointer;
+          border-left:3px solid transparent;
+          transition:background 100ms;
+        ">
+          <span style="font-size:16px;margin-top:1px;">${meta.tool_name ? '🔧' : '🗒️'}</span>
+          <div style="flex:1;min-width:0;">
+            <div style="font-size:13px;color:var(--text-primary);line-height:1.4;word-break:break-word;">
+              ${_snippet(tr, q)}
+            </div>
+            <div style="font-size:11px;color:var(--text-muted);margin-top:2px;">${Utils.esc(tr.agent_id || '')} · ${Utils.esc(tr.title || '')}${when ? ' · ' + Utils.esc(when) : ''}</div>
+          </div>
+        </div>`;
+      });
+    }
+
     _results.innerHTML = html;
     _applySelection();
   }
@@ -288,6 +314,11 @@ window.Search = (function () {
     );
   }
 
+  /* ─── Excerpt: server-side highlight (already escaped) if present ─── */
+  function _snippet(r, q) {
+    return r.highlight || _highlight(Utils.esc(r.excerpt || ''), q);
+  }
+
   /* ─── Keyboard navigation ─── */
   function _move(dir) {
     const newIdx = Math.max(-1, Math.min(_items.length - 1, _selectedIdx + dir));